@@ -0,0 +1,364 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/darkit/process/proclog"
+)
+
+// ApplyAction 配置变更对进程执行的操作
+type ApplyAction string
+
+const (
+	ApplyAdd     ApplyAction = "add"     // 新增进程，设置了自动启动时启动进程
+	ApplyRemove  ApplyAction = "remove"  // 停止并移除进程
+	ApplyRestart ApplyAction = "restart" // 修改了启动相关的配置，按新的配置重启运行中的进程
+	ApplyUpdate  ApplyAction = "update"  // 修改了无需重启的配置(例如日志)或者进程未运行，直接修改进程的配置
+)
+
+// spawnFields 修改后需要重启进程才能生效的配置项
+var spawnFields = map[string]bool{
+	"Command":                  true,
+	"Args":                     true,
+	"Directory":                true,
+	"User":                     true,
+	"Groups":                   true,
+	"Umask":                    true,
+	"Nice":                     true,
+	"IOClass":                  true,
+	"IOPriority":               true,
+	"CPUAffinity":              true,
+	"OOMScoreAdj":              true,
+	"RedirectStderr":           true,
+	"Environment":              true,
+	"RestartWhenBinaryChanged": true,
+	"Isolation":                true,
+}
+
+// logFields 日志相关的配置项，运行中的进程修改后会重新打开日志文件
+var logFields = []string{
+	"StdoutLogfile", "StdoutLogFileMaxBytes", "StdoutLogFileBackups",
+	"StderrLogfile", "StderrLogFileMaxBytes", "StderrLogFileBackups",
+}
+
+// ApplyChange 单个进程的配置变更
+type ApplyChange struct {
+	Name   string      `json:"name"`             // 进程名
+	Action ApplyAction `json:"action"`           // 执行的操作
+	Fields []string    `json:"fields,omitempty"` // 修改的配置项，仅 restart 和 update 有效
+	Error  string      `json:"error,omitempty"`  // 执行失败的原因
+}
+
+// ApplyPlan 配置变更计划
+type ApplyPlan struct {
+	DryRun  bool          `json:"dry_run"` // 是否只生成计划而不执行
+	Changes []ApplyChange `json:"changes"` // 所有进程的变更，按进程名排序，没有变更的进程不包含在内
+}
+
+// applyConfig Manager.Apply 的执行参数
+type applyConfig struct {
+	dryRun  bool
+	author  string
+	managed map[string]bool // 可以被移除的进程，为nil时不限制
+}
+
+// ApplyOption Manager.Apply 的选项函数
+type ApplyOption func(*applyConfig)
+
+// WithDryRun 只生成配置变更计划，不修改任何进程
+func WithDryRun() ApplyOption {
+	return func(config *applyConfig) {
+		config.dryRun = true
+	}
+}
+
+// WithManaged 限定 Apply 移除进程的范围，只有 names 中列出的进程不在期望配置中时才会被停止并移除，
+// 通过其他途径创建的进程保持不变，例如只管理配置文件中的进程时传入上一次加载的进程名；未设置时移除所有不在期望配置中的进程
+func WithManaged(names ...string) ApplyOption {
+	return func(config *applyConfig) {
+		config.managed = make(map[string]bool, len(names))
+		for _, name := range names {
+			config.managed[name] = true
+		}
+	}
+}
+
+// WithAuthor 设置修改人，记录在变更产生的配置版本中
+func WithAuthor(author string) ApplyOption {
+	return func(config *applyConfig) {
+		config.author = author
+	}
+}
+
+// Apply 把进程配置调整为 desired，返回配置变更计划
+//
+// 新增的进程会被创建，设置了自动启动时启动进程；不在 desired 中的进程会被停止并移除，可以通过 WithManaged 限定范围；
+// 修改了启动相关配置(命令、参数、环境变量、用户等)的进程在运行中时会按新的配置重启，
+// 只修改了日志等其他配置的进程直接修改配置，运行中的进程会重新打开日志文件而不重启。
+// 同时发起的多次变更依次执行，后一次在前一次完成后重新生成计划
+func (m *Manager) Apply(desired []Options, opts ...ApplyOption) (ApplyPlan, error) {
+	m.applyLock.Lock()
+	defer m.applyLock.Unlock()
+	config := applyConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	plan := ApplyPlan{DryRun: config.dryRun, Changes: []ApplyChange{}}
+
+	wanted := make(map[string]Options, len(desired))
+	for _, options := range desired {
+		if options.Name == "" {
+			return plan, errors.New("进程配置缺少名称")
+		}
+		if _, exists := wanted[options.Name]; exists {
+			return plan, fmt.Errorf("进程[%s]重复定义", options.Name)
+		}
+		options.ensureMaps()
+		if err := options.Validate(); err != nil {
+			return plan, fmt.Errorf("进程[%s]的配置无效: %w", options.Name, err)
+		}
+		wanted[options.Name] = options
+	}
+
+	m.ForEachProcess(func(p *Process) {
+		name := p.GetName()
+		options, ok := wanted[name]
+		if !ok {
+			if config.managed == nil || config.managed[name] {
+				plan.Changes = append(plan.Changes, ApplyChange{Name: name, Action: ApplyRemove})
+			}
+			return
+		}
+		change, options := planUpdate(p, options)
+		wanted[name] = options
+		if len(change.Fields) > 0 {
+			plan.Changes = append(plan.Changes, change)
+		}
+	})
+	for name := range wanted {
+		if m.Find(name) == nil {
+			plan.Changes = append(plan.Changes, ApplyChange{Name: name, Action: ApplyAdd})
+		}
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Name < plan.Changes[j].Name
+	})
+	if config.dryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+
+	// 先停止移除的和需要重启的进程，再按优先级启动新增的进程
+	var wg sync.WaitGroup
+	var adds []int
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		switch change.Action {
+		case ApplyAdd:
+			adds = append(adds, i)
+			continue
+		case ApplyUpdate:
+			m.executeChange(change, wanted[change.Name])
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			proc := m.Find(change.Name)
+			if change.Action == ApplyRemove {
+				m.logger.Infof("停止并移除进程[%s]", change.Name)
+				proc.Stop(true)
+				if !proc.waitStartExit() {
+					change.Error = "进程停止失败"
+					return
+				}
+				m.Remove(change.Name)
+				return
+			}
+			m.executeChange(change, wanted[change.Name])
+		}()
+	}
+	wg.Wait()
+
+	sort.SliceStable(adds, func(i, j int) bool {
+		return wanted[plan.Changes[adds[i]].Name].Priority < wanted[plan.Changes[adds[j]].Name].Priority
+	})
+	for _, i := range adds {
+		change := &plan.Changes[i]
+		proc, err := m.newProcessByOptions(wanted[change.Name], config.author, 0)
+		if err != nil {
+			change.Error = err.Error()
+			continue
+		}
+		m.logger.Infof("新增进程[%s]", change.Name)
+		if proc.option.AutoStart {
+			proc.Start(false)
+		}
+	}
+
+	var errs []error
+	for _, change := range plan.Changes {
+		switch {
+		case change.Error != "":
+			errs = append(errs, fmt.Errorf("进程[%s]%s失败: %s", change.Name, change.Action, change.Error))
+		case change.Action == ApplyUpdate || change.Action == ApplyRestart:
+			m.recordRevision(wanted[change.Name], config.author, change.Fields, 0)
+		}
+	}
+	return plan, errors.Join(errs...)
+}
+
+// 比较进程当前的配置与目标配置生成变更，没有修改时 Fields 为空，返回补充了继承文件的目标配置
+func planUpdate(p *Process, options Options) (ApplyChange, Options) {
+	p.lock.RLock()
+	if len(options.ExtraFiles) == 0 {
+		// 继承的文件无法通过配置描述，保留进程原有的
+		options.ExtraFiles = p.option.ExtraFiles
+	}
+	fields, restart := diffOptions(p.option, options)
+	p.lock.RUnlock()
+	action := ApplyUpdate
+	if restart && p.isInStart() {
+		action = ApplyRestart
+	}
+	return ApplyChange{Name: options.Name, Action: action, Fields: fields}, options
+}
+
+// 执行 update 或 restart 变更，失败原因记录在 change.Error 中
+func (m *Manager) executeChange(change *ApplyChange, options Options) {
+	proc := m.Find(change.Name)
+	if change.Action == ApplyUpdate {
+		proc.updateOptions(options, change.Fields)
+		m.logger.Infof("修改进程[%s]的配置: %v", change.Name, change.Fields)
+		return
+	}
+	m.logger.Infof("进程[%s]的配置已修改, 按新的配置重启: %v", change.Name, change.Fields)
+	if err := proc.restartWithOptions(options); err != nil {
+		change.Error = err.Error()
+	}
+}
+
+// 直接修改进程的配置，运行中的进程会重写pid文件、重新打开日志文件并按新的配置定时采样资源
+func (that *Process) updateOptions(options Options, fields []string) {
+	changed := func(names ...string) bool {
+		for _, field := range fields {
+			for _, name := range names {
+				if field == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	pid := that.Pid()
+	if pid > 0 && changed("PidFile") {
+		that.removePidFile(pid)
+	}
+	that.option = options
+	if pid <= 0 {
+		return
+	}
+	if changed("PidFile") {
+		that.writePidFile()
+	}
+	if changed(logFields...) {
+		that.reopenLogs()
+	}
+	if changed("StatsInterval", "MemoryLimit", "CPULimit") {
+		that.stopStatsSampler()
+		that.startStatsSampler(pid)
+	}
+}
+
+// 重新打开日志文件，运行中的进程的输出写入新的日志文件
+func (that *Process) reopenLogs() {
+	if stdout, ok := that.stdoutLog.(*proclog.MultiLogger); ok {
+		stdout.Reset(that.createStdoutLogger())
+	}
+	if stderr, ok := that.stderrLog.(*proclog.MultiLogger); ok && !that.option.RedirectStderr {
+		stderr.Reset(that.createStderrLogger())
+	}
+	that.chownLogFiles()
+}
+
+// 按新的配置重启进程，进程未运行时只修改配置
+func (that *Process) restartWithOptions(options Options) error {
+	running := that.isInStart()
+	if running {
+		that.Stop(true)
+		if !that.waitStartExit() {
+			return errors.New("进程停止失败")
+		}
+	}
+	that.lock.Lock()
+	that.option = options
+	that.lock.Unlock()
+	if running {
+		that.Start(false)
+	}
+	return nil
+}
+
+// 比较两个进程配置，返回修改了的配置项，以及是否需要重启进程才能生效
+func diffOptions(current, desired Options) (fields []string, restart bool) {
+	cv, dv := reflect.ValueOf(current), reflect.ValueOf(desired)
+	t := cv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		// 继承的文件不参与比较
+		if name == "ExtraFiles" {
+			continue
+		}
+		if equalValue(cv.Field(i), dv.Field(i)) {
+			continue
+		}
+		fields = append(fields, name)
+		restart = restart || spawnFields[name]
+	}
+	return fields, restart
+}
+
+// 比较配置项的值，空的切片与nil相等，环境变量和扩展参数比较其中的内容
+func equalValue(a, b reflect.Value) bool {
+	if m, ok := a.Interface().(interface{ Map() map[string]string }); ok && !a.IsNil() && !b.IsNil() {
+		return reflect.DeepEqual(m.Map(), b.Interface().(interface{ Map() map[string]string }).Map())
+	}
+	if m, ok := a.Interface().(interface{ Map() map[any]any }); ok && !a.IsNil() && !b.IsNil() {
+		return reflect.DeepEqual(m.Map(), b.Interface().(interface{ Map() map[any]any }).Map())
+	}
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalValue(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equalValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func:
+		// 函数无法直接比较，按函数入口地址比较，同一个函数认为相等
+		return a.Pointer() == b.Pointer()
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
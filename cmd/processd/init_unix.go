@@ -0,0 +1,122 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/darkit/process"
+)
+
+// mainProcessName 容器init模式下主进程的名称
+const mainProcessName = "main"
+
+// forwardSignals 原样转发给所有运行中进程的信号
+var forwardSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH}
+
+// runInit 以容器init进程的方式运行主进程，返回主进程的退出码
+// 主进程为 -- 之后的命令，或者通过 mainName 指定的配置文件中的进程，其他设置了自动启动的进程按优先级在主进程之前启动；
+// 收到 SIGTERM/SIGINT 时按各进程的 StopSignal 停止所有进程，其他信号原样转发，主进程退出后停止所有进程并退出
+func runInit(manager *process.Manager, mainName string, args []string) int {
+	if mainName != "" && len(args) > 0 {
+		slog.Error("The main process is given both by -main and after --")
+		return 2
+	}
+	if mainName == "" && len(args) == 0 {
+		slog.Error("No main process given, usage: processd -init -- command [args...] or processd -init -main name")
+		return 2
+	}
+	if err := manager.EnableSubreaper(); err != nil {
+		slog.Warn("Failed to enable subreaper", slog.Any("err", err))
+	}
+
+	var proc *process.Process
+	if mainName != "" {
+		if proc = manager.Find(mainName); proc == nil {
+			slog.Error("The main process is not configured", slog.String("name", mainName))
+			return 2
+		}
+	} else {
+		var err error
+		proc, err = manager.NewProcess(
+			process.WithName(mainProcessName),
+			process.WithCommand(args[0]),
+			process.WithArgs(args[1:]...),
+			process.WithAutoReStart(process.AutoReStartFalse),
+			process.WithStartSecs(0),
+			process.WithStopSignal("TERM"),
+			process.WithStdoutLog("/dev/stdout", ""),
+			process.WithStderrLog("/dev/stderr", ""),
+		)
+		if err != nil {
+			slog.Error("Failed to create main process", slog.Any("err", err))
+			return 1
+		}
+	}
+
+	exited := make(chan int, 1)
+	manager.Subscribe(func(e process.Event) {
+		if e.Type == process.EventExited && e.Process == proc.GetName() {
+			select {
+			case exited <- e.ExitCode:
+			default:
+			}
+		}
+	})
+
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, append([]os.Signal{syscall.SIGTERM, syscall.SIGINT}, forwardSignals...)...)
+
+	startAutoStartPrograms(manager, proc)
+	proc.Start(true)
+	if proc.GetState() == process.Fatal {
+		slog.Error("Failed to start main process", slog.String("err", proc.GetSpawnErr()))
+		manager.StopAllProcesses()
+		return 1
+	}
+
+	for {
+		select {
+		case code := <-exited:
+			slog.Info("Main process exited", slog.Int("code", code))
+			manager.StopAllProcesses()
+			return code
+		case sig := <-sigs:
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				slog.Info("Received signal, stopping all processes", slog.Any("signal", sig))
+				go manager.StopAllProcesses()
+				continue
+			}
+			manager.ForEachProcess(func(p *process.Process) {
+				if p.Pid() > 0 {
+					_ = p.Signal(sig, false)
+				}
+			})
+		}
+	}
+}
+
+// startAutoStartPrograms 按优先级依次启动主进程以外设置了自动启动的进程
+func startAutoStartPrograms(manager *process.Manager, main *process.Process) {
+	var procs []*process.Process
+	manager.ForEachProcess(func(p *process.Process) {
+		if p != main && p.IsAutoStart() {
+			procs = append(procs, p)
+		}
+	})
+	sort.Slice(procs, func(i, j int) bool {
+		pi, pj := procs[i].Options().Priority, procs[j].Options().Priority
+		if pi != pj {
+			return pi < pj
+		}
+		return procs[i].GetName() < procs[j].GetName()
+	})
+	for _, p := range procs {
+		p.Start(true)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/darkit/process"
+)
+
+// runInit Windows 不支持容器init模式
+func runInit(_ *process.Manager, _ string, _ []string) int {
+	slog.Error("Init mode is not supported on windows")
+	return 2
+}
@@ -2,45 +2,241 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/darkit/process"
+	"github.com/darkit/process/config"
+	"github.com/darkit/process/handlers"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs processd and returns the exit code, os.Exit is only called by main so that deferred cleanups run
+func run() int {
 	var configFile string
+	var initMode bool
+	var pidFile string
+	var printSchema bool
+	var mainName string
 
-	flag.StringVar(&configFile, "config", "config.yaml", "Configuration file path")
+	flag.StringVar(&configFile, "config", "config.yaml", "Configuration file path (.yaml, .yml, .json, .toml, or supervisord .ini/.conf)")
+	flag.BoolVar(&initMode, "init", false, "Run as container init (PID 1), the main process is given after -- or by -main")
+	flag.StringVar(&mainName, "main", "", "In init mode, use the configured program with this name as the main process")
+	flag.StringVar(&pidFile, "pidfile", "", "Manager pid file, prevents running multiple instances")
+	flag.BoolVar(&printSchema, "schema", false, "Print the JSON Schema of the configuration file and exit")
 	flag.Parse()
 
+	if printSchema {
+		_, _ = os.Stdout.Write(config.JSONSchema())
+		return 0
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to load config: %v", err))
+		return 1
+	}
+	closer, err := setupLogger(cfg.Log)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to setup logger: %v", err))
+		return 1
+	}
+	defer closer.Close()
+	for _, warning := range cfg.Warnings {
+		slog.Warn(warning)
+	}
+
 	manager := process.NewManager()
 
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if pidFile != "" {
+		if err := manager.LockPidFile(pidFile); err != nil {
+			slog.Error(fmt.Sprintf("Failed to lock pid file: %v", err))
+			return 1
+		}
+		defer func() { _ = manager.ReleasePidFile() }()
+	}
+
+	programs := &configPrograms{file: configFile}
+	if initMode {
+		programs.main = mainName
+	}
+	var options []process.Options
+	options, programs.names = programs.options(cfg)
+	for _, opts := range options {
+		if _, err := manager.NewProcessByOptions(opts); err != nil {
+			slog.Error(fmt.Sprintf("Failed to create process: %v", err))
+		}
+	}
+
+	if cfg.HTTP.Listen != "" {
+		server := &http.Server{Addr: cfg.HTTP.Listen, Handler: newAPIHandler(manager, cfg.HTTP.Prefix, programs)}
+		go func() {
+			slog.Info(fmt.Sprintf("HTTP API is listening on %s", cfg.HTTP.Listen))
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error(fmt.Sprintf("HTTP API stopped: %v", err))
+			}
+		}()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+		}()
+	}
+
+	if initMode {
+		return runInit(manager, mainName, flag.Args())
+	}
+
+	reload := func() error {
+		_, err := programs.reload(manager, false)
+		return err
+	}
+	if err := manager.Run(context.Background(), process.WithReloadHandler(reload)); err != nil {
+		slog.Error(fmt.Sprintf("Failed to stop processes: %v", err))
+	}
+	return 0
+}
+
+// loadConfig loads the configuration file, a missing default config file is not an error
+func loadConfig(file string) (*config.Config, error) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			explicit = true
+		}
+	})
+	if _, err := os.Stat(file); !explicit && errors.Is(err, os.ErrNotExist) {
+		slog.Warn(fmt.Sprintf("Config file %s not found, no process is configured", file))
+		return &config.Config{}, nil
+	}
+	return config.Load(file)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// configPrograms tracks the programs loaded from the configuration file, reloading only removes these programs,
+// processes created through the HTTP API and the init main process given after -- are kept
+type configPrograms struct {
+	lock  sync.Mutex
+	file  string
+	main  string   // the configured main process in init mode
+	names []string // names of the programs in the last applied config
+}
 
-	go func() {
-		sig := <-sigChan
-		slog.Info("Received signal", slog.Any("sigChan", sig))
-		cancel()
-	}()
+// options returns the programs of cfg and their names,
+// the init main process is started by runInit and never restarted
+func (that *configPrograms) options(cfg *config.Config) ([]process.Options, []string) {
+	programs := make([]process.Options, 0, len(cfg.Programs))
+	names := make([]string, 0, len(cfg.Programs))
+	for _, opts := range cfg.Programs {
+		if opts.Name == that.main {
+			opts.AutoStart = false
+			opts.AutoReStart = process.AutoReStartFalse
+		}
+		programs = append(programs, opts)
+		names = append(names, opts.Name)
+	}
+	return programs, names
+}
 
-	// Example process creation
-	proc, err := manager.NewProcessCmd("echo 'Hello World'", nil)
+// reload reloads the configuration file and applies the programs to the manager,
+// the HTTP and log settings only take effect after a restart
+func (that *configPrograms) reload(manager *process.Manager, dryRun bool) (process.ApplyPlan, error) {
+	cfg, err := loadConfig(that.file)
 	if err != nil {
-		slog.Error(fmt.Sprintf("Failed to create process: %v", err))
+		return process.ApplyPlan{}, err
+	}
+	for _, warning := range cfg.Warnings {
+		slog.Warn(warning)
 	}
 
-	proc.Start(true)
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	opts := []process.ApplyOption{process.WithManaged(that.names...)}
+	if dryRun {
+		opts = append(opts, process.WithDryRun())
+	}
+	programs, names := that.options(cfg)
+	plan, err := manager.Apply(programs, opts...)
+	if !dryRun {
+		that.names = names
+	}
+	for _, change := range plan.Changes {
+		slog.Info(fmt.Sprintf("Config change: %s %s %v", change.Action, change.Name, change.Fields))
+	}
+	return plan, err
+}
+
+// setupLogger replaces the default slog logger according to the log config
+func setupLogger(cfg config.LogConfig) (io.Closer, error) {
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, err
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(out, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+	return out, nil
+}
 
-	<-ctx.Done()
-	manager.StopAllProcesses()
+// newAPIHandler creates the HTTP API routes, optionally mounted under prefix,
+// POST /config/reload reloads the configuration file, dry_run=true only returns the plan
+func newAPIHandler(manager *process.Manager, prefix string, programs *configPrograms) http.Handler {
+	api := handlers.NewProcessHandler(manager, func(h http.HandlerFunc) http.HandlerFunc {
+		return h
+	})
+	mux := api.SetupRoutes()
+	mux.HandleFunc("/config/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		plan, err := programs.reload(manager, dryRun)
+		resp := map[string]interface{}{"code": 0, "data": plan}
+		status := http.StatusOK
+		if err != nil {
+			resp["code"], resp["msg"] = -1, err.Error()
+			status = http.StatusInternalServerError
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	if prefix == "" {
+		return mux
+	}
+	root := http.NewServeMux()
+	root.Handle(prefix+"/", http.StripPrefix(prefix, mux))
+	return root
 }
+
+// nopCloser keeps os.Stderr open when the logger is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
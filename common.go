@@ -36,6 +36,20 @@ func getShellOption() string {
 	}
 }
 
+// 解析命令行，可以直接启动的命令返回命令和参数，并把命令前的环境变量写入 env；
+// 需要shell处理的命令行通过shell执行，单个命令加上 exec 前缀
+func parseCommandLine(cmd string, env *utils.StrStrMap) (string, []string) {
+	if runtime.GOOS != "windows" {
+		line, err := utils.ParseCommandLine(cmd)
+		if err == nil && !line.Shell && len(line.Args) > 0 {
+			env.Sets(line.Env)
+			return line.Args[0], line.Args[1:]
+		}
+		cmd = utils.ExecCommand(cmd)
+	}
+	return getShell(), append([]string{getShellOption()}, parseCommand(cmd)...)
+}
+
 func parseCommand(cmd string) (args []string) {
 	if runtime.GOOS != "windows" {
 		return []string{cmd}
@@ -0,0 +1,87 @@
+// Package config 加载进程配置，支持 YAML、JSON、TOML 格式的声明式配置以及 supervisord 格式的INI配置文件
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darkit/process"
+)
+
+// Format 配置文件格式
+type Format string
+
+const (
+	FormatYAML Format = "yaml" // YAML格式，扩展名 .yaml、.yml
+	FormatJSON Format = "json" // JSON格式，扩展名 .json
+	FormatTOML Format = "toml" // TOML格式，扩展名 .toml
+	FormatINI  Format = "ini"  // supervisord 的INI格式，扩展名 .ini、.conf
+)
+
+// Config 配置文件的解析结果
+type Config struct {
+	HTTP     HTTPConfig        // HTTP API 监听配置，仅声明式配置支持
+	Log      LogConfig         // 管理器日志配置，仅声明式配置支持
+	Programs []process.Options // 进程配置，按文件中的顺序排列
+	Groups   []Group           // 进程组，仅INI配置支持
+	Warnings []string          // 不支持而被忽略的配置
+}
+
+// HTTPConfig HTTP API 监听配置
+type HTTPConfig struct {
+	Listen string // 监听地址，例如 ":9001"，为空表示不开启 HTTP API
+	Prefix string // 接口路径前缀，例如 "/api"，默认没有前缀
+}
+
+// LogConfig 管理器日志配置
+type LogConfig struct {
+	Level  string // 日志级别，可选值：[debug,info,warn,error]，默认info
+	Format string // 日志格式，可选值：[text,json]，默认text
+	File   string // 日志文件，默认输出到标准错误
+}
+
+// FormatOf 根据文件扩展名判断配置文件格式
+func FormatOf(file string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".ini", ".conf":
+		return FormatINI, nil
+	default:
+		return "", fmt.Errorf("无法根据扩展名判断配置文件格式: %s", file)
+	}
+}
+
+// Load 加载配置文件，根据扩展名选择格式
+func Load(file string) (*Config, error) {
+	format, err := FormatOf(file)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatINI {
+		return LoadINI(file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, format, file)
+}
+
+// Parse 解析指定格式的配置内容，file 用于错误信息，INI格式还用于解析 [include] 中的相对路径
+func Parse(data []byte, format Format, file string) (*Config, error) {
+	switch format {
+	case FormatYAML, FormatJSON, FormatTOML:
+		return parseDocument(data, format, file)
+	case FormatINI:
+		return ParseINI(data, file)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s", format)
+	}
+}
@@ -0,0 +1,368 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/utils"
+)
+
+// yamlErrorLine YAML解析错误中的行号，例如 "yaml: line 3: did not find expected key"
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// 解析 YAML、JSON、TOML 格式的声明式配置
+//
+// 三种格式都先转换为 YAML 的节点树再统一解析，节点中记录了行号，出错时可以指出文件、行号和配置项
+func parseDocument(data []byte, format Format, file string) (*Config, error) {
+	root, err := parseNode(data, format, file)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{file: file}
+	config := d.decode(root)
+	if len(d.errs) > 0 {
+		return nil, errors.Join(d.errs...)
+	}
+	return config, nil
+}
+
+// 把配置内容解析为节点树
+func parseNode(data []byte, format Format, file string) (*yaml.Node, error) {
+	if format == FormatTOML {
+		return parseTOML(data, file)
+	}
+	if format == FormatJSON {
+		// JSON 是 YAML 的子集，但需要先按 JSON 的语法检查，避免接受 JSON 中不合法的内容
+		var value any
+		if err := json.Unmarshal(data, &value); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+				return nil, &Error{File: file, Line: line, Err: err}
+			}
+			return nil, &Error{File: file, Err: err}
+		}
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if parts := yamlErrorLine.FindStringSubmatch(err.Error()); parts != nil {
+			line, _ := strconv.Atoi(parts[1])
+			return nil, &Error{File: file, Line: line, Err: errors.New(parts[2])}
+		}
+		return nil, &Error{File: file, Err: err}
+	}
+	if len(doc.Content) == 0 {
+		// 空文件
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// decoder 把节点树解析为配置，并收集所有错误
+type decoder struct {
+	file string  // 配置文件
+	errs []error // 解析过程中的错误
+}
+
+// 记录错误
+func (d *decoder) fail(node *yaml.Node, field string, err error) {
+	if err == nil {
+		return
+	}
+	line := 0
+	if node != nil {
+		line = node.Line
+	}
+	d.errs = append(d.errs, &Error{File: d.file, Line: line, Field: field, Err: err})
+}
+
+// 遍历映射节点中的配置项，fn 返回 false 表示未知的配置项
+//
+// 支持 YAML 的锚点和 <<: 合并，合并进来的配置项会被同名的配置项覆盖
+func (d *decoder) mapping(node *yaml.Node, field string, fn func(key string, value *yaml.Node, field string) bool) {
+	node = resolve(node)
+	if isNull(node) {
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		d.fail(node, field, errors.New("应为映射"))
+		return
+	}
+	type entry struct {
+		key, value *yaml.Node
+	}
+	var entries []entry
+	index := make(map[string]int)
+	add := func(key, value *yaml.Node, merged bool) {
+		if i, ok := index[key.Value]; ok {
+			if !merged {
+				entries[i] = entry{key, value}
+			}
+			return
+		}
+		index[key.Value] = len(entries)
+		entries = append(entries, entry{key, value})
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag != "!!merge" {
+			add(key, value, false)
+			continue
+		}
+		sources := []*yaml.Node{resolve(value)}
+		if sources[0].Kind == yaml.SequenceNode {
+			sources = sources[0].Content
+		}
+		for _, source := range sources {
+			source = resolve(source)
+			if source.Kind != yaml.MappingNode {
+				d.fail(source, field, errors.New("只能合并映射"))
+				continue
+			}
+			for j := 0; j+1 < len(source.Content); j += 2 {
+				add(source.Content[j], source.Content[j+1], true)
+			}
+		}
+	}
+	for _, e := range entries {
+		name := joinField(field, e.key.Value)
+		if !fn(e.key.Value, resolve(e.value), name) {
+			d.fail(e.key, name, errors.New("未知的配置项"))
+		}
+	}
+}
+
+// 遍历序列节点中的元素，单个值按只有一个元素的序列处理
+func (d *decoder) sequence(node *yaml.Node, field string, fn func(value *yaml.Node, field string)) {
+	node = resolve(node)
+	switch {
+	case isNull(node):
+	case node.Kind == yaml.SequenceNode:
+		for i, value := range node.Content {
+			fn(resolve(value), field+"["+strconv.Itoa(i)+"]")
+		}
+	default:
+		fn(node, field)
+	}
+}
+
+// 解析整个配置文件
+func (d *decoder) decode(root *yaml.Node) *Config {
+	config := &Config{}
+	var defaults, programs *yaml.Node
+	d.mapping(root, "", func(key string, value *yaml.Node, field string) bool {
+		switch key {
+		case "http":
+			d.decodeHTTP(value, field, &config.HTTP)
+		case "log":
+			d.decodeLog(value, field, &config.Log)
+		case "defaults":
+			defaults = value
+		case "programs":
+			programs = value
+		default:
+			return false
+		}
+		return true
+	})
+
+	// defaults 中的配置作为所有进程的默认值，只解析一次，避免同一个错误在每个进程中重复出现
+	base := process.NewOptions()
+	if defaults != nil {
+		d.decodeProgram(&base, defaults, "defaults")
+	}
+	if programs == nil {
+		return config
+	}
+	d.mapping(programs, "programs", func(name string, value *yaml.Node, field string) bool {
+		opts := base.Clone()
+		opts.Name = name
+		n := len(d.errs)
+		d.decodeProgram(&opts, value, field)
+		// 解析出错时配置不完整，不再校验，避免重复报错
+		if len(d.errs) == n {
+			d.validate(&opts, field, value, defaults)
+		}
+		config.Programs = append(config.Programs, opts)
+		return true
+	})
+	return config
+}
+
+// 校验进程配置，把错误对应到配置文件中的行，配置项依次在 nodes 中查找，都没有找到时使用第一个节点的行号
+func (d *decoder) validate(opts *process.Options, field string, nodes ...*yaml.Node) {
+	var validationErr *process.ValidationError
+	if !errors.As(opts.Validate(), &validationErr) {
+		return
+	}
+	for _, err := range validationErr.Errors {
+		path := strings.Split(err.Field, ".")
+		node := nodes[0]
+		for _, n := range nodes {
+			if found := findNode(n, path); found != nil {
+				node = found
+				break
+			}
+		}
+		d.fail(node, field+"."+err.Field, err.Err)
+	}
+}
+
+// 解析 HTTP API 监听配置
+func (d *decoder) decodeHTTP(node *yaml.Node, field string, config *HTTPConfig) {
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		var err error
+		switch key {
+		case "listen":
+			config.Listen, err = toString(value)
+		case "prefix":
+			if config.Prefix, err = toString(value); err == nil && config.Prefix != "" && !strings.HasPrefix(config.Prefix, "/") {
+				err = errors.New("路径前缀必须以 / 开头")
+			}
+			config.Prefix = strings.TrimSuffix(config.Prefix, "/")
+		default:
+			return false
+		}
+		d.fail(value, field, err)
+		return true
+	})
+}
+
+// 解析管理器日志配置
+func (d *decoder) decodeLog(node *yaml.Node, field string, config *LogConfig) {
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		var err error
+		switch key {
+		case "level":
+			if config.Level, err = toString(value); err == nil {
+				var level slog.Level
+				if err = level.UnmarshalText([]byte(config.Level)); err != nil {
+					err = errors.New("无效的日志级别，可选值：[debug,info,warn,error]")
+				}
+			}
+		case "format":
+			if config.Format, err = toString(value); err == nil && config.Format != "text" && config.Format != "json" {
+				err = errors.New("无效的日志格式，可选值：[text,json]")
+			}
+		case "file":
+			config.File, err = toString(value)
+		default:
+			return false
+		}
+		d.fail(value, field, err)
+		return true
+	})
+}
+
+// 拼接配置项路径
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// 解析别名节点
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// 是否为空值，例如 "key:" 或 "key: null"
+func isNull(node *yaml.Node) bool {
+	return node == nil || (node.Kind == yaml.ScalarNode && node.Tag == "!!null")
+}
+
+// 读取字符串
+func toString(node *yaml.Node) (string, error) {
+	if isNull(node) {
+		return "", nil
+	}
+	if node.Kind != yaml.ScalarNode {
+		return "", errors.New("应为单个值")
+	}
+	return node.Value, nil
+}
+
+// 读取布尔值
+func toBool(node *yaml.Node) (bool, error) {
+	value, err := toString(node)
+	if err != nil {
+		return false, err
+	}
+	return parseBool(value)
+}
+
+// 读取整数
+func toInt(node *yaml.Node) (int, error) {
+	value, err := toString(node)
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(value)
+}
+
+// 读取时长，没有单位时表示秒
+func toDuration(node *yaml.Node) (time.Duration, error) {
+	value, err := toString(node)
+	if err != nil {
+		return 0, err
+	}
+	return utils.ParseDuration(value)
+}
+
+// 读取字符串列表，单个值使用 split 分割
+func toStrings(node *yaml.Node, split func(string) ([]string, error)) ([]string, error) {
+	node = resolve(node)
+	if node.Kind != yaml.SequenceNode {
+		value, err := toString(node)
+		if err != nil {
+			return nil, err
+		}
+		return split(value)
+	}
+	list := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		value, err := toString(resolve(item))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+// 读取整数列表，单个值按逗号分割
+func toInts(node *yaml.Node) ([]int, error) {
+	values, err := toStrings(node, func(value string) ([]string, error) {
+		return splitList(value), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]int, 0, len(values))
+	for _, value := range values {
+		n, err := parseInt(value)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// 按逗号和空白字符分割单个值
+func splitWords(value string) ([]string, error) {
+	return splitList(value), nil
+}
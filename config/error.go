@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Error 配置错误，包含出错的文件、行号和配置项
+type Error struct {
+	File  string // 配置文件
+	Line  int    // 行号，从1开始，为0表示无法确定行号
+	Field string // 配置项，例如 program:web.startsecs
+	Err   error  // 错误原因
+}
+
+// Error 实现 error 接口，格式为 "文件:行号: 配置项: 错误原因"
+func (e *Error) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %v", pos, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", pos, e.Field, e.Err)
+}
+
+// Unwrap 返回错误原因
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/utils"
+)
+
+func init() {
+	process.RegisterConfigExporter(string(FormatINI), ExportINI)
+	process.RegisterConfigExporter(string(FormatYAML), ExportYAML)
+	process.RegisterConfigExporter(string(FormatJSON), ExportJSON)
+}
+
+// optionKeys 进程配置项按 process.Options 中字段顺序排列的名称，进程名不是配置项
+var optionKeys = func() []string {
+	var keys []string
+	t := reflect.TypeOf(process.Options{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "name" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}()
+
+// supervisordKeys supervisord 原有的进程配置项，其他配置项以扩展配置项的形式导出
+var supervisordKeys = map[string]bool{
+	"command":                 true,
+	"directory":               true,
+	"priority":                true,
+	"autostart":               true,
+	"autorestart":             true,
+	"startsecs":               true,
+	"startretries":            true,
+	"exitcodes":               true,
+	"stopsignal":              true,
+	"stopwaitsecs":            true,
+	"stopasgroup":             true,
+	"killasgroup":             true,
+	"user":                    true,
+	"umask":                   true,
+	"redirect_stderr":         true,
+	"stdout_logfile":          true,
+	"stdout_logfile_maxbytes": true,
+	"stdout_logfile_backups":  true,
+	"stderr_logfile":          true,
+	"stderr_logfile_maxbytes": true,
+	"stderr_logfile_backups":  true,
+	"environment":             true,
+}
+
+// iniUnsafe INI中无法原样保存的值：换行、首尾空白以及会被当作行内注释的 ;
+var iniUnsafe = regexp.MustCompile(`[\r\n]|\s;|^\s|\s$`)
+
+// ExportJSON 把进程配置导出为 JSON 格式的声明式配置，只写出与默认值不同的配置项，可以通过 Parse 重新加载
+func ExportJSON(programs []process.Options) ([]byte, error) {
+	doc, err := exportDocument(programs)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = json.Indent(&buf, doc, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// ExportYAML 把进程配置导出为 YAML 格式的声明式配置，只写出与默认值不同的配置项，可以通过 Parse 重新加载
+func ExportYAML(programs []process.Options) ([]byte, error) {
+	doc, err := exportDocument(programs)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err = yaml.Unmarshal(doc, &node); err != nil {
+		return nil, err
+	}
+	resetStyle(&node)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportINI 把进程配置导出为 supervisord 格式的配置，只写出与 supervisord 默认值不同的配置项，可以通过 ParseINI 重新加载
+//
+// supervisord 没有的配置项使用声明式配置中的名称，值为 YAML 的流式写法，例如 hooks={"pre_start":["./migrate"]}，
+// supervisord 本身会忽略这些配置项
+func ExportINI(programs []process.Options) ([]byte, error) {
+	base, err := iniValues(defaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	defaults := make(map[string]string, len(base))
+	for _, entry := range base {
+		defaults[entry.Key] = entry.Value
+	}
+	var buf bytes.Buffer
+	for i, opts := range programs {
+		if strings.ContainsAny(opts.Name, "]\r\n") || strings.TrimSpace(opts.Name) != opts.Name {
+			return nil, fmt.Errorf("进程名[%s]无法导出为INI格式", opts.Name)
+		}
+		entries, err := iniValues(opts)
+		if err != nil {
+			return nil, fmt.Errorf("进程[%s]: %w", opts.Name, err)
+		}
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "[program:%s]\n", opts.Name)
+		for _, entry := range entries {
+			if entry.Key != "command" && defaults[entry.Key] == entry.Value {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s=%s\n", entry.Key, strings.ReplaceAll(entry.Value, "%", "%%"))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// 生成紧凑的 JSON 格式声明式配置，配置项按字段顺序排列，与 process.NewOptions 相同的配置项不写出
+func exportDocument(programs []process.Options) ([]byte, error) {
+	base, err := programValues(process.NewOptions())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"programs":{`)
+	for i, opts := range programs {
+		values, err := programValues(opts)
+		if err != nil {
+			return nil, fmt.Errorf("进程[%s]: %w", opts.Name, err)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(opts.Name)
+		buf.Write(name)
+		buf.WriteString(":{")
+		first := true
+		for _, key := range optionKeys {
+			value, ok := values[key]
+			old, inBase := base[key]
+			switch {
+			case !ok && !inBase:
+				continue
+			case !ok:
+				// 空列表被省略，默认值不为空时需要写出 null 清空
+				value = json.RawMessage("null")
+			case inBase && bytes.Equal(value, old):
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(&buf, "%q:", key)
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString("}}")
+	return buf.Bytes(), nil
+}
+
+// 把进程配置转换为配置项名称和 JSON 值，command 是包含参数的完整命令行，不再单独写出 args
+func programValues(opts process.Options) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	delete(values, "name")
+	delete(values, "args")
+	values["command"], _ = json.Marshal(utils.JoinCommand(append([]string{opts.Command}, opts.Args...)))
+	return values, nil
+}
+
+// 把进程配置转换为 supervisord 的配置项，按字段顺序排列
+func iniValues(opts process.Options) ([]iniEntry, error) {
+	values, err := programValues(opts)
+	if err != nil {
+		return nil, err
+	}
+	var entries []iniEntry
+	for _, key := range optionKeys {
+		raw, ok := values[key]
+		if !ok {
+			raw = json.RawMessage("null")
+		}
+		name, ok := iniKeys[key]
+		if !ok {
+			name = key
+		}
+		var value string
+		switch {
+		case key == "command":
+			if strings.ContainsAny(opts.Command, "\r\n") {
+				return nil, fmt.Errorf("命令[%q]无法导出为INI格式", opts.Command)
+			}
+			value = utils.JoinCommand(append([]string{opts.Command}, opts.Args...))
+			if strings.ContainsAny(value, "\r\n") {
+				// 参数中有换行时命令行无法写在一行中，参数使用扩展配置项 args 写出
+				entries = append(entries, iniEntry{Key: name, Value: utils.JoinCommand([]string{opts.Command})})
+				args, _ := json.Marshal(opts.Args)
+				name, value = "args", extensionValue(args)
+			}
+		case key == "environment":
+			if value, err = formatEnvironment(opts.Environment.Map()); err != nil {
+				return nil, err
+			}
+		case key == "exit_codes" || key == "stop_signal":
+			var list []json.RawMessage
+			if err = json.Unmarshal(raw, &list); err != nil {
+				return nil, err
+			}
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				items = append(items, jsonScalar(item))
+			}
+			value = strings.Join(items, ",")
+		case supervisordKeys[name]:
+			value = jsonScalar(raw)
+			if iniUnsafe.MatchString(value) {
+				return nil, fmt.Errorf("%s[%q]无法导出为INI格式", name, value)
+			}
+		default:
+			value = extensionValue(raw)
+		}
+		entries = append(entries, iniEntry{Key: name, Value: value})
+	}
+	return entries, nil
+}
+
+// 把 JSON 的单个值转换为字符串，null 转换为空字符串，数值保持原样
+func jsonScalar(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// 把 JSON 值转换为扩展配置项的值，普通字符串不加引号，其他值使用 JSON 写法(也是合法的 YAML 流式写法)
+func extensionValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil && plainScalar(s) {
+		return s
+	}
+	// ; 前有空白时会被当作行内注释，字符串中的 ; 统一转义
+	return strings.ReplaceAll(string(raw), ";", `\u003b`)
+}
+
+// 字符串是否可以不加引号写在扩展配置项中，按 YAML 解析后仍然是原来的字符串
+func plainScalar(s string) bool {
+	if s == "" || iniUnsafe.MatchString(s) || strings.ContainsAny(s, ";#") {
+		return false
+	}
+	var node yaml.Node
+	if yaml.Unmarshal([]byte(s), &node) != nil || len(node.Content) == 0 {
+		return false
+	}
+	scalar := node.Content[0]
+	return scalar.Kind == yaml.ScalarNode && scalar.Style == 0 && scalar.Tag != "!!null" && scalar.Value == s
+}
+
+// 把环境变量转换为 supervisord 的格式，KEY="value" 之间用逗号分隔，按变量名排序
+func formatEnvironment(env map[string]string) (string, error) {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, ";", `\;`)
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.ContainsAny(key, "=,\"' \t\r\n") || strings.ContainsAny(env[key], "\r\n") {
+			return "", fmt.Errorf("环境变量[%s]无法导出为INI格式", key)
+		}
+		items = append(items, key+`="`+escaper.Replace(env[key])+`"`)
+	}
+	return strings.Join(items, ","), nil
+}
+
+// 清除从 JSON 解析得到的引号和流式风格，输出为块风格的 YAML
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
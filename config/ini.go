@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// iniSection INI文件中的一个小节
+type iniSection struct {
+	Name    string     // 小节名，例如 program:web
+	File    string     // 所在文件
+	Line    int        // 小节名所在行号
+	Entries []iniEntry // 配置项，按文件中的顺序排列
+}
+
+// iniEntry INI文件中的一个配置项
+type iniEntry struct {
+	Key   string // 配置项名称，统一为小写
+	Value string // 配置项的值，续行之间用换行符连接
+	Line  int    // 配置项所在行号
+}
+
+// 获取配置项，不存在时返回nil
+func (that *iniSection) get(key string) *iniEntry {
+	for i := len(that.Entries) - 1; i >= 0; i-- {
+		if that.Entries[i].Key == key {
+			return &that.Entries[i]
+		}
+	}
+	return nil
+}
+
+// inlineComment 行内注释，与 supervisord 一致，只有前面带空白字符的 ; 才表示注释
+var inlineComment = regexp.MustCompile(`\s;.*$`)
+
+// parseINI 解析INI格式的内容
+// 支持 ; 和 # 开头的注释行、行内注释、以空白字符开头的续行，以及 = 或 : 分隔的配置项
+func parseINI(data []byte, file string) ([]*iniSection, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var sections []*iniSection
+	var current *iniSection
+	var entry *iniEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			entry = nil
+			continue
+		}
+		if trimmed[0] == ';' || trimmed[0] == '#' {
+			continue
+		}
+		// 以空白字符开头的行是上一个配置项的续行
+		if raw[0] == ' ' || raw[0] == '\t' {
+			if entry == nil {
+				return nil, &Error{File: file, Line: lineNo, Err: fmt.Errorf("续行之前没有配置项")}
+			}
+			entry.Value += "\n" + strings.TrimSpace(inlineComment.ReplaceAllString(trimmed, ""))
+			continue
+		}
+		if trimmed[0] == '[' {
+			end := strings.IndexByte(trimmed, ']')
+			if end < 0 {
+				return nil, &Error{File: file, Line: lineNo, Err: fmt.Errorf("小节名缺少 ]")}
+			}
+			current = &iniSection{Name: strings.TrimSpace(trimmed[1:end]), File: file, Line: lineNo}
+			sections = append(sections, current)
+			entry = nil
+			continue
+		}
+		if current == nil {
+			return nil, &Error{File: file, Line: lineNo, Err: fmt.Errorf("配置项不在任何小节中")}
+		}
+		pos := strings.IndexAny(trimmed, "=:")
+		if pos <= 0 {
+			return nil, &Error{File: file, Line: lineNo, Err: fmt.Errorf("无法解析的行: %s", trimmed)}
+		}
+		current.Entries = append(current.Entries, iniEntry{
+			Key:   strings.ToLower(strings.TrimSpace(trimmed[:pos])),
+			Value: strings.TrimSpace(inlineComment.ReplaceAllString(trimmed[pos+1:], "")),
+			Line:  lineNo,
+		})
+		entry = &current.Entries[len(current.Entries)-1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &Error{File: file, Line: lineNo, Err: err}
+	}
+	return sections, nil
+}
@@ -0,0 +1,403 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/utils"
+)
+
+// programField 声明式配置中进程配置项的解析函数，返回的错误记录在配置项的值上
+type programField func(d *decoder, opts *process.Options, node *yaml.Node, field string) error
+
+// programFields 进程的配置项，与 process.Options 的字段一一对应，进程名使用 programs 下的键
+var programFields map[string]programField
+
+func init() {
+	programFields = map[string]programField{
+		"command": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) error {
+			args, err := toStrings(node, utils.SplitCommand)
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 || args[0] == "" {
+				return errors.New("命令不能为空")
+			}
+			opts.Command = args[0]
+			if len(args) > 1 {
+				opts.Args = args[1:]
+			}
+			return nil
+		},
+		"args":         stringsField(func(opts *process.Options) *[]string { return &opts.Args }, utils.SplitCommand),
+		"command_line": stringField(func(opts *process.Options) *string { return &opts.CommandLine }),
+		"directory":    stringField(func(opts *process.Options) *string { return &opts.Directory }),
+		"pid_file":     stringField(func(opts *process.Options) *string { return &opts.PidFile }),
+		"auto_start":   boolField(func(opts *process.Options) *bool { return &opts.AutoStart }),
+		"start_secs":   durationField(process.WithStartDuration),
+		"auto_restart": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			var value string
+			if value, err = toString(node); err == nil {
+				opts.AutoReStart, err = parseAutoRestart(value)
+			}
+			return err
+		},
+		"exit_codes": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			opts.ExitCodes, err = toInts(node)
+			return err
+		},
+		"start_retries": intField(func(opts *process.Options) *int { return &opts.StartRetries }),
+		"restart_pause": durationField(process.WithRestartPauseDuration),
+		"user":          stringField(func(opts *process.Options) *string { return &opts.User }),
+		"groups":        stringsField(func(opts *process.Options) *[]string { return &opts.Groups }, splitWords),
+		"umask":         stringField(func(opts *process.Options) *string { return &opts.Umask }),
+		"priority":      intField(func(opts *process.Options) *int { return &opts.Priority }),
+		"nice":          intField(func(opts *process.Options) *int { return &opts.Nice }),
+		"io_class":      stringField(func(opts *process.Options) *string { return &opts.IOClass }),
+		"io_priority":   intField(func(opts *process.Options) *int { return &opts.IOPriority }),
+		"cpu_affinity": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			opts.CPUAffinity, err = toInts(node)
+			return err
+		},
+		"oom_score_adj": intField(func(opts *process.Options) *int { return &opts.OOMScoreAdj }),
+
+		"stdout_logfile":           stringField(func(opts *process.Options) *string { return &opts.StdoutLogfile }),
+		"stdout_logfile_max_bytes": sizeField(func(opts *process.Options) *int { return &opts.StdoutLogFileMaxBytes }),
+		"stdout_logfile_backups":   intField(func(opts *process.Options) *int { return &opts.StdoutLogFileBackups }),
+		"redirect_stderr":          boolField(func(opts *process.Options) *bool { return &opts.RedirectStderr }),
+		"stderr_logfile":           stringField(func(opts *process.Options) *string { return &opts.StderrLogfile }),
+		"stderr_logfile_max_bytes": sizeField(func(opts *process.Options) *int { return &opts.StderrLogFileMaxBytes }),
+		"stderr_logfile_backups":   intField(func(opts *process.Options) *int { return &opts.StderrLogFileBackups }),
+
+		"stop_as_group": boolField(func(opts *process.Options) *bool { return &opts.StopAsGroup }),
+		"kill_as_group": boolField(func(opts *process.Options) *bool { return &opts.KillAsGroup }),
+		"stop_as_tree":  boolField(func(opts *process.Options) *bool { return &opts.StopAsTree }),
+		"stop_signal": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) error {
+			signals, err := toStrings(node, splitWords)
+			if err != nil {
+				return err
+			}
+			for i := range signals {
+				signals[i] = strings.ToUpper(signals[i])
+			}
+			opts.StopSignal = signals
+			return nil
+		},
+		"stop_plan": func(d *decoder, opts *process.Options, node *yaml.Node, field string) error {
+			opts.StopPlan = d.decodeStopPlan(node, field)
+			return nil
+		},
+		"stop_wait_secs": durationField(process.WithStopWaitDuration),
+		"kill_wait_secs": durationField(process.WithKillWaitDuration),
+		"environment": func(d *decoder, opts *process.Options, node *yaml.Node, field string) error {
+			d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+				val, err := toString(value)
+				d.fail(value, field, err)
+				opts.Environment.Set(key, val)
+				return true
+			})
+			return nil
+		},
+		"restart_when_binary_changed": boolField(func(opts *process.Options) *bool { return &opts.RestartWhenBinaryChanged }),
+		"extend": func(d *decoder, opts *process.Options, node *yaml.Node, field string) error {
+			d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+				var val any
+				d.fail(value, field, value.Decode(&val))
+				opts.Extend.Set(key, val)
+				return true
+			})
+			return nil
+		},
+		"isolation": func(d *decoder, opts *process.Options, node *yaml.Node, field string) error {
+			d.decodeIsolation(opts, node, field)
+			return nil
+		},
+		"hooks": func(d *decoder, opts *process.Options, node *yaml.Node, field string) error {
+			d.decodeHooks(&opts.Hooks, node, field)
+			return nil
+		},
+
+		"stats_interval": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			opts.StatsInterval, err = toDuration(node)
+			return err
+		},
+		"stats_with_children": boolField(func(opts *process.Options) *bool { return &opts.StatsWithChildren }),
+		"memory_limit": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			var value string
+			if value, err = toString(node); err == nil {
+				opts.MemoryLimit, err = utils.ParseSize(value)
+			}
+			return err
+		},
+		"memory_limit_duration": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			opts.MemoryLimitDuration, err = toDuration(node)
+			return err
+		},
+		"cpu_limit": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			var value string
+			if value, err = toString(node); err == nil {
+				opts.CPULimit, err = parseFloat(value)
+			}
+			return err
+		},
+		"cpu_limit_duration": func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+			opts.CPULimitDuration, err = toDuration(node)
+			return err
+		},
+	}
+}
+
+// 解析进程配置，列表和单个值会覆盖原有的配置，environment、extend 以及 isolation、hooks 中的配置项逐项合并
+func (d *decoder) decodeProgram(opts *process.Options, node *yaml.Node, field string) {
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		fn, ok := programFields[key]
+		if !ok {
+			return false
+		}
+		d.fail(value, field, fn(d, opts, value, field))
+		return true
+	})
+}
+
+// 解析停止计划
+func (d *decoder) decodeStopPlan(node *yaml.Node, field string) *process.StopPlan {
+	plan := &process.StopPlan{}
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		switch key {
+		case "steps":
+			d.sequence(value, field, func(value *yaml.Node, field string) {
+				plan.Steps = append(plan.Steps, d.decodeStopStep(value, field))
+			})
+		case "kill_timeout":
+			var err error
+			plan.KillTimeout, err = toDuration(value)
+			d.fail(value, field, err)
+		default:
+			return false
+		}
+		return true
+	})
+	return plan
+}
+
+// 解析停止计划中的一个步骤
+func (d *decoder) decodeStopStep(node *yaml.Node, field string) process.StopStep {
+	var step process.StopStep
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		var err error
+		switch key {
+		case "signal":
+			step.Signal, err = toString(value)
+			step.Signal = strings.ToUpper(step.Signal)
+		case "http":
+			step.HTTP, err = toString(value)
+		case "http_method":
+			step.HTTPMethod, err = toString(value)
+			step.HTTPMethod = strings.ToUpper(step.HTTPMethod)
+		case "command":
+			step.Command, err = toString(value)
+		case "timeout":
+			step.Timeout, err = toDuration(value)
+		case "wait":
+			step.Wait, err = toDuration(value)
+		default:
+			return false
+		}
+		d.fail(value, field, err)
+		return true
+	})
+	return step
+}
+
+// 解析进程隔离配置，与 defaults 中的隔离配置逐项合并
+func (d *decoder) decodeIsolation(opts *process.Options, node *yaml.Node, field string) {
+	isolation := &process.Isolation{}
+	if opts.Isolation != nil {
+		*isolation = *opts.Isolation
+	}
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		var err error
+		switch key {
+		case "chroot":
+			isolation.Chroot, err = toString(value)
+		case "namespaces":
+			isolation.Namespaces, err = toStrings(value, splitWords)
+		case "uid_mappings":
+			isolation.UidMappings = d.decodeIDMaps(value, field)
+		case "gid_mappings":
+			isolation.GidMappings = d.decodeIDMaps(value, field)
+		case "no_new_privs":
+			isolation.NoNewPrivs, err = toBool(value)
+		case "ambient_caps":
+			isolation.AmbientCaps, err = toStrings(value, splitWords)
+		default:
+			return false
+		}
+		d.fail(value, field, err)
+		return true
+	})
+	opts.Isolation = isolation
+}
+
+// 解析 user 命名空间的id映射
+func (d *decoder) decodeIDMaps(node *yaml.Node, field string) []process.IDMap {
+	var mappings []process.IDMap
+	d.sequence(node, field, func(value *yaml.Node, field string) {
+		var mapping process.IDMap
+		d.mapping(value, field, func(key string, value *yaml.Node, field string) bool {
+			var err error
+			switch key {
+			case "container_id":
+				mapping.ContainerID, err = toInt(value)
+			case "host_id":
+				mapping.HostID, err = toInt(value)
+			case "size":
+				mapping.Size, err = toInt(value)
+			default:
+				return false
+			}
+			d.fail(value, field, err)
+			return true
+		})
+		mappings = append(mappings, mapping)
+	})
+	return mappings
+}
+
+// 解析生命周期钩子，设置了的阶段会覆盖 defaults 中同一阶段的钩子
+func (d *decoder) decodeHooks(hooks *process.Hooks, node *yaml.Node, field string) {
+	d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+		var list []process.Hook
+		d.sequence(value, field, func(value *yaml.Node, field string) {
+			list = append(list, d.decodeHook(value, field))
+		})
+		switch process.HookStage(key) {
+		case process.HookPreStart:
+			hooks.PreStart = list
+		case process.HookPostStart:
+			hooks.PostStart = list
+		case process.HookPreStop:
+			hooks.PreStop = list
+		case process.HookPostStop:
+			hooks.PostStop = list
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// 解析单个钩子，只写命令时可以简写为字符串
+func (d *decoder) decodeHook(node *yaml.Node, field string) process.Hook {
+	var hook process.Hook
+	if node.Kind == yaml.ScalarNode {
+		hook.Command = node.Value
+	} else {
+		d.mapping(node, field, func(key string, value *yaml.Node, field string) bool {
+			var err error
+			switch key {
+			case "command":
+				hook.Command, err = toString(value)
+			case "timeout":
+				hook.Timeout, err = toDuration(value)
+			case "abort_on_failure":
+				hook.AbortOnFailure, err = toBool(value)
+			default:
+				return false
+			}
+			d.fail(value, field, err)
+			return true
+		})
+	}
+	return hook
+}
+
+// 字符串类型的配置项
+func stringField(target func(*process.Options) *string) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+		*target(opts), err = toString(node)
+		return err
+	}
+}
+
+// 字符串列表类型的配置项，单个值使用 split 分割
+func stringsField(target func(*process.Options) *[]string, split func(string) ([]string, error)) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+		*target(opts), err = toStrings(node, split)
+		return err
+	}
+}
+
+// 布尔类型的配置项
+func boolField(target func(*process.Options) *bool) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+		*target(opts), err = toBool(node)
+		return err
+	}
+}
+
+// 整数类型的配置项
+func intField(target func(*process.Options) *int) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) (err error) {
+		*target(opts), err = toInt(node)
+		return err
+	}
+}
+
+// 以秒为单位的时长配置项，数值表示秒数，也支持 "500ms"、"2m" 这样的时长
+func durationField(with func(time.Duration) process.WithOption) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) error {
+		d, err := toDuration(node)
+		if err == nil {
+			with(d)(opts)
+		}
+		return err
+	}
+}
+
+// 容量类型的配置项，例如 "50MB"
+func sizeField(target func(*process.Options) *int) programField {
+	return func(_ *decoder, opts *process.Options, node *yaml.Node, _ string) error {
+		value, err := toString(node)
+		if err != nil {
+			return err
+		}
+		size, err := utils.ParseSize(value)
+		*target(opts) = int(size)
+		return err
+	}
+}
+
+// 在节点树中查找配置项，例如 []string{"stop_plan", "steps[0]", "signal"}，没有找到时返回nil
+func findNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, part := range path {
+		name, index, _ := strings.Cut(part, "[")
+		node = resolve(node)
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				value = node.Content[i+1]
+			}
+		}
+		if value == nil {
+			return nil
+		}
+		node = resolve(value)
+		if index != "" {
+			i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+			if node.Kind != yaml.SequenceNode || err != nil || i >= len(node.Content) {
+				return node
+			}
+			node = resolve(node.Content[i])
+		}
+	}
+	return node
+}
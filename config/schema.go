@@ -0,0 +1,15 @@
+package config
+
+import (
+	_ "embed"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// JSONSchema 返回声明式配置的 JSON Schema，可用于编辑器的自动补全和校验
+//
+// YAML 文件可以在第一行添加 "# yaml-language-server: $schema=<schema文件路径>" 启用校验
+func JSONSchema() []byte {
+	return append([]byte(nil), schema...)
+}
@@ -0,0 +1,456 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/utils"
+)
+
+// errIgnored 不支持而被忽略的配置项
+var errIgnored = errors.New("不支持的配置项，已忽略")
+
+// ignoredKeys supervisord 中存在但当前不支持的配置项
+var ignoredKeys = map[string]bool{
+	"serverurl":               true,
+	"stdout_capture_maxbytes": true,
+	"stdout_events_enabled":   true,
+	"stdout_syslog":           true,
+	"stderr_capture_maxbytes": true,
+	"stderr_events_enabled":   true,
+	"stderr_syslog":           true,
+}
+
+// ignoredSections supervisord 自身的配置小节，与进程无关
+var ignoredSections = map[string]bool{
+	"include":          true,
+	"supervisord":      true,
+	"supervisorctl":    true,
+	"unix_http_server": true,
+	"inet_http_server": true,
+	"rpcinterface":     true,
+}
+
+// Group supervisord 的进程组
+type Group struct {
+	Name     string   // 组名
+	Programs []string // 组内的程序名
+	Priority int      // 组的优先级，默认999
+}
+
+// LoadINI 加载 supervisord 格式的配置文件，通过 [include] 引入的文件也会被加载
+func LoadINI(file string) (*Config, error) {
+	loader := &iniLoader{visited: make(map[string]bool)}
+	if err := loader.load(file); err != nil {
+		return nil, err
+	}
+	return loader.build()
+}
+
+// ParseINI 解析 supervisord 格式的配置内容，file 用于展开 %(here)s、解析 [include] 中的相对路径以及错误信息
+func ParseINI(data []byte, file string) (*Config, error) {
+	loader := &iniLoader{visited: make(map[string]bool)}
+	if err := loader.parse(data, file); err != nil {
+		return nil, err
+	}
+	return loader.build()
+}
+
+// iniLoader 加载INI文件及其引入的文件
+type iniLoader struct {
+	visited  map[string]bool // 已经加载过的文件，避免循环引入
+	sections []*iniSection   // 所有文件中的小节
+	warnings []string        // 加载过程中的警告
+}
+
+// 加载配置文件
+func (that *iniLoader) load(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if that.visited[abs] {
+		return nil
+	}
+	that.visited[abs] = true
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	return that.parse(data, abs)
+}
+
+// 解析配置内容，并加载 [include] 中引入的文件
+func (that *iniLoader) parse(data []byte, file string) error {
+	sections, err := parseINI(data, file)
+	if err != nil {
+		return err
+	}
+	that.sections = append(that.sections, sections...)
+	here := filepath.Dir(file)
+	for _, section := range sections {
+		if section.Name != "include" {
+			continue
+		}
+		entry := section.get("files")
+		if entry == nil {
+			continue
+		}
+		value, err := expand(entry.Value, map[string]string{"here": here})
+		if err != nil {
+			return &Error{File: file, Line: entry.Line, Field: "include.files", Err: err}
+		}
+		for _, pattern := range strings.Fields(value) {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(here, pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return &Error{File: file, Line: entry.Line, Field: "include.files", Err: err}
+			}
+			if len(matches) == 0 {
+				that.warnings = append(that.warnings, (&Error{File: file, Line: entry.Line, Field: "include.files", Err: fmt.Errorf("没有匹配的文件: %s", pattern)}).Error())
+			}
+			for _, match := range matches {
+				if err = that.load(match); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 根据所有小节生成配置
+func (that *iniLoader) build() (*Config, error) {
+	config := &Config{Warnings: that.warnings}
+	var errs []error
+	names := make(map[string]bool)
+	sections := make(map[string][]string) // [program:x] 小节名与生成的进程名的对应关系
+	var groupSections []*iniSection
+	for _, section := range that.sections {
+		kind, name, _ := strings.Cut(section.Name, ":")
+		switch {
+		case kind == "program":
+			programs, warnings, err := buildPrograms(section, name)
+			config.Warnings = append(config.Warnings, warnings...)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, opts := range programs {
+				if names[opts.Name] {
+					errs = append(errs, &Error{File: section.File, Line: section.Line, Field: section.Name, Err: fmt.Errorf("进程[%s]重复定义", opts.Name)})
+					continue
+				}
+				names[opts.Name] = true
+				sections[name] = append(sections[name], opts.Name)
+				config.Programs = append(config.Programs, opts)
+			}
+		case kind == "group":
+			groupSections = append(groupSections, section)
+		case ignoredSections[kind]:
+		default:
+			config.Warnings = append(config.Warnings, (&Error{File: section.File, Line: section.Line, Field: section.Name, Err: errors.New("不支持的小节，已忽略")}).Error())
+		}
+	}
+	for _, section := range groupSections {
+		group, err := buildGroup(section, sections)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		config.Groups = append(config.Groups, group)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return config, nil
+}
+
+// 根据 [group:x] 小节生成进程组，programs 中列出的是 [program:x] 的小节名，
+// 展开为该小节生成的所有进程名(设置了 numprocs 时有多个)
+func buildGroup(section *iniSection, programs map[string][]string) (Group, error) {
+	group := Group{Name: strings.TrimPrefix(section.Name, "group:"), Priority: 999}
+	var errs []error
+	for _, entry := range section.Entries {
+		field := section.Name + "." + entry.Key
+		switch entry.Key {
+		case "programs":
+			for _, name := range splitList(entry.Value) {
+				procs, ok := programs[name]
+				if !ok {
+					errs = append(errs, &Error{File: section.File, Line: entry.Line, Field: field, Err: fmt.Errorf("程序[%s]不存在", name)})
+					continue
+				}
+				group.Programs = append(group.Programs, procs...)
+			}
+		case "priority":
+			n, err := parseInt(entry.Value)
+			if err != nil {
+				errs = append(errs, &Error{File: section.File, Line: entry.Line, Field: field, Err: err})
+			}
+			group.Priority = n
+		default:
+			errs = append(errs, &Error{File: section.File, Line: entry.Line, Field: field, Err: errors.New("未知的配置项")})
+		}
+	}
+	return group, errors.Join(errs...)
+}
+
+// 根据 [program:x] 小节生成进程配置，设置了 numprocs 时会生成多个进程
+func buildPrograms(section *iniSection, name string) ([]process.Options, []string, error) {
+	hostname, _ := os.Hostname()
+	vars := map[string]string{
+		"here":           filepath.Dir(section.File),
+		"program_name":   name,
+		"group_name":     name,
+		"host_node_name": hostname,
+		"process_num":    "0",
+	}
+	// 读取数值类型的配置项
+	intValue := func(key string, def int) (int, error) {
+		entry := section.get(key)
+		if entry == nil {
+			return def, nil
+		}
+		value, err := expand(entry.Value, vars)
+		if err == nil {
+			def, err = parseInt(value)
+		}
+		if err != nil {
+			return 0, &Error{File: section.File, Line: entry.Line, Field: section.Name + "." + key, Err: err}
+		}
+		return def, nil
+	}
+	numProcs, err := intValue("numprocs", 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	numProcsStart, err := intValue("numprocs_start", 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	processName := "%(program_name)s"
+	if entry := section.get("process_name"); entry != nil {
+		processName = entry.Value
+	}
+	if numProcs > 1 && !strings.Contains(processName, "%(process_num)") {
+		return nil, nil, &Error{File: section.File, Line: section.Line, Field: section.Name + ".process_name", Err: errors.New("numprocs 大于1时 process_name 中必须包含 %(process_num)")}
+	}
+
+	var programs []process.Options
+	var warnings []string
+	var errs []error
+	for i := 0; i < numProcs; i++ {
+		vars["process_num"] = strconv.Itoa(numProcsStart + i)
+		opts := defaultOptions()
+		n := len(errs)
+		if opts.Name, err = expand(processName, vars); err != nil {
+			errs = append(errs, &Error{File: section.File, Line: section.Line, Field: section.Name + ".process_name", Err: err})
+			continue
+		}
+		for _, entry := range section.Entries {
+			if entry.Key == "numprocs" || entry.Key == "numprocs_start" || entry.Key == "process_name" {
+				continue
+			}
+			field := section.Name + "." + entry.Key
+			value, err := expand(entry.Value, vars)
+			if err == nil {
+				err = applyProgramKey(&opts, entry.Key, value)
+			}
+			if errors.Is(err, errIgnored) {
+				// 多个进程共用同一个小节，只需要提示一次
+				if i == 0 {
+					warnings = append(warnings, (&Error{File: section.File, Line: entry.Line, Field: field, Err: err}).Error())
+				}
+				continue
+			}
+			if err != nil {
+				errs = append(errs, &Error{File: section.File, Line: entry.Line, Field: field, Err: err})
+			}
+		}
+		if n == len(errs) {
+			errs = append(errs, validateProgram(section, &opts)...)
+		}
+		programs = append(programs, opts)
+	}
+	return programs, warnings, errors.Join(errs...)
+}
+
+// iniKeys 与 supervisord 名称不同的配置项，用于定位校验错误所在的行
+var iniKeys = map[string]string{
+	"auto_start":               "autostart",
+	"auto_restart":             "autorestart",
+	"start_secs":               "startsecs",
+	"start_retries":            "startretries",
+	"exit_codes":               "exitcodes",
+	"stop_signal":              "stopsignal",
+	"stop_wait_secs":           "stopwaitsecs",
+	"stop_as_group":            "stopasgroup",
+	"kill_as_group":            "killasgroup",
+	"stdout_logfile_max_bytes": "stdout_logfile_maxbytes",
+	"stderr_logfile_max_bytes": "stderr_logfile_maxbytes",
+}
+
+// 校验进程配置，错误使用对应配置项所在的行，没有对应的配置项时使用小节所在的行
+func validateProgram(section *iniSection, opts *process.Options) []error {
+	var validationErr *process.ValidationError
+	if !errors.As(opts.Validate(), &validationErr) {
+		return nil
+	}
+	errs := make([]error, 0, len(validationErr.Errors))
+	for _, err := range validationErr.Errors {
+		name, _, _ := strings.Cut(err.Field, "[")
+		key, ok := iniKeys[name]
+		if !ok {
+			key = name
+		}
+		line := section.Line
+		if entry := section.get(key); entry != nil {
+			line = entry.Line
+		}
+		errs = append(errs, &Error{File: section.File, Line: line, Field: section.Name + "." + key, Err: err.Err})
+	}
+	return errs
+}
+
+// 创建使用 supervisord 默认值的进程配置
+func defaultOptions() process.Options {
+	opts := process.NewOptions()
+	opts.AutoReStart = process.AutoReStartUnexpected
+	opts.StopSignal = []string{"TERM"}
+	opts.StopWaitSecs = 10
+	opts.ExitCodes = []int{0}
+	return opts
+}
+
+// 把 supervisord 的配置项设置到进程配置中
+func applyProgramKey(opts *process.Options, key, value string) (err error) {
+	switch key {
+	case "command":
+		var args []string
+		if args, err = utils.SplitCommand(value); err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return errors.New("命令不能为空")
+		}
+		opts.Command, opts.Args = args[0], args[1:]
+	case "directory":
+		opts.Directory = value
+	case "priority":
+		opts.Priority, err = parseInt(value)
+	case "autostart":
+		opts.AutoStart, err = parseBool(value)
+	case "autorestart":
+		opts.AutoReStart, err = parseAutoRestart(value)
+	case "startsecs":
+		err = applyDuration(opts, value, process.WithStartDuration)
+	case "startretries":
+		opts.StartRetries, err = parseInt(value)
+	case "exitcodes":
+		opts.ExitCodes, err = parseIntList(value)
+	case "stopsignal":
+		opts.StopSignal = splitList(strings.ToUpper(value))
+	case "stopwaitsecs":
+		err = applyDuration(opts, value, process.WithStopWaitDuration)
+	case "stopasgroup":
+		// 与 supervisord 一致，stopasgroup 同时开启 killasgroup
+		if opts.StopAsGroup, err = parseBool(value); opts.StopAsGroup {
+			opts.KillAsGroup = true
+		}
+	case "killasgroup":
+		opts.KillAsGroup, err = parseBool(value)
+	case "user":
+		opts.User = value
+	case "umask":
+		opts.Umask = value
+	case "redirect_stderr":
+		opts.RedirectStderr, err = parseBool(value)
+	case "stdout_logfile":
+		opts.StdoutLogfile, err = parseLogfile(value)
+	case "stdout_logfile_maxbytes":
+		var size uint64
+		size, err = utils.ParseSize(value)
+		opts.StdoutLogFileMaxBytes = int(size)
+	case "stdout_logfile_backups":
+		opts.StdoutLogFileBackups, err = parseInt(value)
+	case "stderr_logfile":
+		opts.StderrLogfile, err = parseLogfile(value)
+	case "stderr_logfile_maxbytes":
+		var size uint64
+		size, err = utils.ParseSize(value)
+		opts.StderrLogFileMaxBytes = int(size)
+	case "stderr_logfile_backups":
+		opts.StderrLogFileBackups, err = parseInt(value)
+	case "environment":
+		var env map[string]string
+		if env, err = parseEnvironment(value); err == nil {
+			opts.Environment.Sets(env)
+		}
+	default:
+		if ignoredKeys[key] {
+			return errIgnored
+		}
+		if field, ok := programFields[key]; ok {
+			return applyExtensionKey(opts, field, key, value)
+		}
+		return errors.New("未知的配置项")
+	}
+	return err
+}
+
+// 设置 supervisord 没有的扩展配置项，配置项与声明式配置相同，值为 YAML 的流式写法，例如 hooks={"pre_start":["./migrate"]}
+func applyExtensionKey(opts *process.Options, field programField, key, value string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return fmt.Errorf("无效的值: %w", err)
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	if len(doc.Content) > 0 {
+		node = doc.Content[0]
+	}
+	d := &decoder{}
+	d.fail(node, key, field(d, opts, node, key))
+	errs := make([]error, 0, len(d.errs))
+	for _, err := range d.errs {
+		// 错误已经记录在配置项上，不再重复文件和行号
+		e := err.(*Error)
+		if e.Field != key {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.TrimPrefix(e.Field, key+"."), e.Err))
+			continue
+		}
+		errs = append(errs, e.Err)
+	}
+	return errors.Join(errs...)
+}
+
+// 解析时长并设置到进程配置中，除了 supervisord 的整数秒，也支持 "500ms" 这样的时长
+func applyDuration(opts *process.Options, value string, with func(time.Duration) process.WithOption) error {
+	d, err := utils.ParseDuration(value)
+	if err == nil {
+		with(d)(opts)
+	}
+	return err
+}
+
+// 解析日志文件配置，NONE 表示不记录日志，不支持 supervisord 自动生成日志文件的 AUTO
+func parseLogfile(value string) (string, error) {
+	switch strings.ToUpper(value) {
+	case "NONE":
+		return "/dev/null", nil
+	case "AUTO":
+		return "", fmt.Errorf("不支持 AUTO，请指定日志文件: %w", errIgnored)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 解析TOML格式的配置，转换为YAML的节点树
+//
+// TOML解析器不提供配置项的位置，行号通过扫描配置内容中的表头和键得到，
+// 内联表和数组中的配置项使用所在键的行号
+func parseTOML(data []byte, file string) (*yaml.Node, error) {
+	var values map[string]any
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &Error{File: file, Line: parseErr.Position.Line, Err: errors.New(parseErr.Message)}
+		}
+		return nil, &Error{File: file, Err: err}
+	}
+	return tomlNode(values, "", 1, tomlKeyLines(data)), nil
+}
+
+// 把TOML的值转换为节点，path 为值的路径，数组元素使用下标表示，例如 programs.web.hooks.pre_start.0
+func tomlNode(value any, path string, line int, lines map[string]int) *yaml.Node {
+	if l, ok := lines[path]; ok {
+		line = l
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		// 按配置项在文件中的顺序排列，保证进程的顺序与文件一致
+		sort.Slice(keys, func(i, j int) bool {
+			li, lj := lines[joinField(path, keys[i])], lines[joinField(path, keys[j])]
+			if li != lj {
+				return li < lj
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			child := tomlNode(v[key], joinField(path, key), line, lines)
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: child.Line}
+			node.Content = append(node.Content, keyNode, child)
+		}
+		return node
+	case []map[string]any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for i, item := range v {
+			node.Content = append(node.Content, tomlNode(item, joinField(path, strconv.Itoa(i)), line, lines))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for i, item := range v {
+			node.Content = append(node.Content, tomlNode(item, joinField(path, strconv.Itoa(i)), line, lines))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v, Line: line}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v), Line: line}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10), Line: line}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v, 'f', -1, 64), Line: line}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v.Format(time.RFC3339Nano), Line: line}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v), Line: line}
+	}
+}
+
+// 扫描TOML配置内容，得到表头和键所在的行号
+func tomlKeyLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	tables := make(map[string]int) // 表数组中已经出现的表的数量
+	set := func(path []string, line int) {
+		for i := range path {
+			key := strings.Join(path[:i+1], ".")
+			if _, ok := lines[key]; !ok {
+				lines[key] = line
+			}
+		}
+	}
+	var table []string
+	multiline := ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if multiline != "" {
+			// 多行字符串中的内容不是配置项
+			if strings.Count(line, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+		switch {
+		case line == "" || line[0] == '#':
+		case strings.HasPrefix(line, "[["):
+			end := strings.Index(line, "]]")
+			if end < 0 {
+				continue
+			}
+			path := splitTOMLKey(line[2:end])
+			name := strings.Join(path, ".")
+			table = append(path, strconv.Itoa(tables[name]))
+			tables[name]++
+			set(table, i+1)
+		case line[0] == '[':
+			end := strings.LastIndex(line, "]")
+			if end < 0 {
+				continue
+			}
+			table = splitTOMLKey(line[1:end])
+			set(table, i+1)
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			set(append(append([]string{}, table...), splitTOMLKey(key)...), i+1)
+			for _, quote := range []string{`"""`, `'''`} {
+				if strings.Count(value, quote)%2 == 1 {
+					multiline = quote
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// 分割TOML中以点连接的键，支持带引号的键，例如 programs."web.v2"
+func splitTOMLKey(key string) []string {
+	var parts []string
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; c {
+		case '"', '\'':
+			end := strings.IndexByte(key[i+1:], c)
+			if end < 0 {
+				end = len(key) - i - 1
+			}
+			sb.WriteString(key[i+1 : i+1+end])
+			i += end + 1
+		case '.':
+			parts = append(parts, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(parts, strings.TrimSpace(sb.String()))
+}
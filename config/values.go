@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/darkit/process"
+)
+
+// parseBool 解析布尔值，支持 true/false、yes/no、on/off、1/0
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("无效的布尔值: %q", value)
+	}
+}
+
+// parseInt 解析整数
+func parseInt(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("无效的整数: %q", value)
+	}
+	return n, nil
+}
+
+// parseFloat 解析浮点数
+func parseFloat(value string) (float64, error) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的数值: %q", value)
+	}
+	return n, nil
+}
+
+// parseAutoRestart 解析自动重启策略，可选值：[unexpected,true,false]
+func parseAutoRestart(value string) (process.AutoReStart, error) {
+	var restart process.AutoReStart
+	err := restart.UnmarshalText([]byte(value))
+	return restart, err
+}
+
+// parseIntList 解析逗号分隔的整数列表，例如 "0,2"
+func parseIntList(value string) ([]int, error) {
+	var list []int
+	for _, item := range splitList(value) {
+		n, err := parseInt(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// splitList 按逗号和空白字符分割列表，忽略空项
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// parseEnvironment 解析环境变量，格式为 KEY="value",KEY2=value2，值中包含逗号时需要加引号
+func parseEnvironment(value string) (map[string]string, error) {
+	env := make(map[string]string)
+	s := strings.TrimSpace(value)
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("无效的环境变量: %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t\n")
+		var val string
+		if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+			quote := s[0]
+			var sb strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' && quote == '"' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("环境变量[%s]的值缺少结束引号", key)
+			}
+			val = sb.String()
+			s = strings.TrimLeft(s[i+1:], " \t\n")
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		env[key] = val
+		if len(s) > 0 {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("环境变量[%s]之后缺少逗号", key)
+			}
+			s = strings.TrimLeft(s[1:], " \t\n")
+		}
+	}
+	return env, nil
+}
+
+// expansion supervisord 风格的变量引用，例如 %(here)s、%(process_num)02d
+var expansion = regexp.MustCompile(`%\(([A-Za-z0-9_]+)\)([-#0 +]*[0-9]*)([sd])|%%`)
+
+// expand 展开值中的变量引用，ENV_ 开头的变量从环境变量中读取，%% 表示 %
+func expand(value string, vars map[string]string) (string, error) {
+	var errs []error
+	result := expansion.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "%%" {
+			return "%"
+		}
+		parts := expansion.FindStringSubmatch(match)
+		name, flags, verb := parts[1], parts[2], parts[3]
+		val, ok := vars[name]
+		if !ok && strings.HasPrefix(name, "ENV_") {
+			val, ok = os.LookupEnv(strings.TrimPrefix(name, "ENV_"))
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("未定义的变量: %s", name))
+			return match
+		}
+		if verb == "d" {
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("变量[%s]不是整数: %q", name, val))
+				return match
+			}
+			return fmt.Sprintf("%"+flags+"d", n)
+		}
+		return fmt.Sprintf("%"+flags+"s", val)
+	})
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,28 @@
+// Package converter 在进程配置与其他格式之间转换：
+// 导入 foreman/honcho 的 Procfile 和 .env 文件，导出和导入 systemd 的 service 单元文件
+package converter
+
+import (
+	"time"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/utils"
+)
+
+// 通过shell执行的命令使用的解释器，与 Procfile 工具和进程的钩子一致
+const shell = "/bin/sh"
+
+// 以秒为单位的配置项实际生效的时长，时长字段不为0时优先
+func effectiveDuration(secs int, duration time.Duration) time.Duration {
+	if duration != 0 {
+		return duration
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// 把通过shell执行的命令转换为进程的命令和参数，单个命令加上 exec 前缀，停止信号直接发送给该命令
+func shellCommand(opts *process.Options, command string) {
+	opts.Command = shell
+	opts.Args = []string{"-c", utils.ExecCommand(command)}
+	opts.CommandLine = command
+}
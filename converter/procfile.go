@@ -0,0 +1,263 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/config"
+)
+
+// DefaultBasePort 与 foreman 一致的默认起始端口
+const DefaultBasePort = 5000
+
+// procfileLine Procfile 中的一行，格式为 "进程类型: 命令"
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// envKey .env 文件中的变量名
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ProcfileEntry Procfile 中的一个进程类型
+type ProcfileEntry struct {
+	Name    string // 进程类型，例如 web
+	Command string // 启动命令，通过 /bin/sh -c 执行
+	Line    int    // 所在行号
+}
+
+// procfileConfig Procfile 的转换参数
+type procfileConfig struct {
+	basePort  int
+	formation map[string]int
+	env       map[string]string
+	envFile   string
+	directory string
+}
+
+// ProcfileOption Procfile 转换的选项函数
+type ProcfileOption func(*procfileConfig)
+
+// WithBasePort 设置起始端口，默认使用环境变量 PORT，未设置时为5000
+func WithBasePort(port int) ProcfileOption {
+	return func(config *procfileConfig) {
+		config.basePort = port
+	}
+}
+
+// WithFormation 设置每个进程类型的实例数，例如 {"web": 2, "worker": 1}，
+// 未设置的进程类型启动1个实例，设置为0时不启动该进程类型
+func WithFormation(formation map[string]int) ProcfileOption {
+	return func(config *procfileConfig) {
+		config.formation = formation
+	}
+}
+
+// WithEnv 设置所有进程的环境变量，覆盖 .env 文件中的同名变量
+func WithEnv(env map[string]string) ProcfileOption {
+	return func(config *procfileConfig) {
+		config.env = env
+	}
+}
+
+// WithEnvFile 设置 LoadProcfile 加载的 .env 文件，默认为 Procfile 所在目录下的 .env，文件不存在时忽略
+func WithEnvFile(file string) ProcfileOption {
+	return func(config *procfileConfig) {
+		config.envFile = file
+	}
+}
+
+// WithDirectory 设置进程的运行目录，LoadProcfile 默认使用 Procfile 所在的目录
+func WithDirectory(dir string) ProcfileOption {
+	return func(config *procfileConfig) {
+		config.directory = dir
+	}
+}
+
+// LoadProcfile 加载 Procfile 和 .env 文件，转换为进程配置
+func LoadProcfile(file string, opts ...ProcfileOption) ([]process.Options, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseProcfile(data, file)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	cfg := procfileConfig{envFile: filepath.Join(dir, ".env"), directory: dir}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	env := make(map[string]string)
+	if data, err = os.ReadFile(cfg.envFile); err == nil {
+		if env, err = ParseEnv(data, cfg.envFile); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for key, val := range cfg.env {
+		env[key] = val
+	}
+	// 选项已经合并，按合并后的结果转换
+	return ProcfileOptions(entries, WithEnv(env), WithDirectory(cfg.directory), WithBasePort(cfg.basePort), WithFormation(cfg.formation)), nil
+}
+
+// ParseProcfile 解析 Procfile，每行的格式为 "进程类型: 命令"，忽略空行和 # 开头的注释
+func ParseProcfile(data []byte, file string) ([]ProcfileEntry, error) {
+	var entries []ProcfileEntry
+	var errs []error
+	seen := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			errs = append(errs, &config.Error{File: file, Line: i + 1, Err: errors.New("无效的格式，应为 \"进程类型: 命令\"")})
+			continue
+		}
+		if first, ok := seen[match[1]]; ok {
+			errs = append(errs, &config.Error{File: file, Line: i + 1, Field: match[1], Err: fmt.Errorf("进程类型重复定义，第一次定义在第%d行", first)})
+			continue
+		}
+		seen[match[1]] = i + 1
+		entries = append(entries, ProcfileEntry{Name: match[1], Command: strings.TrimSpace(match[2]), Line: i + 1})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// ParseEnv 解析 .env 文件，每行的格式为 KEY=VALUE，支持 export 前缀、单引号、双引号以及行尾的 # 注释，
+// 双引号中支持 \n、\t、\"、\\ 转义，不展开变量引用
+func ParseEnv(data []byte, file string) (map[string]string, error) {
+	env := make(map[string]string)
+	var errs []error
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKey.MatchString(key) {
+			errs = append(errs, &config.Error{File: file, Line: i + 1, Err: errors.New("无效的格式，应为 KEY=VALUE")})
+			continue
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, &config.Error{File: file, Line: i + 1, Field: key, Err: err})
+			continue
+		}
+		env[key] = value
+	}
+	return env, errors.Join(errs...)
+}
+
+// 解析 .env 中变量的值
+func parseEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	var result string
+	var rest string
+	switch value[0] {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", errors.New("单引号没有闭合")
+		}
+		result, rest = value[1:1+end], value[2+end:]
+	case '"':
+		var sb strings.Builder
+		i := 1
+		for ; i < len(value) && value[i] != '"'; i++ {
+			if value[i] == '\\' && i+1 < len(value) {
+				i++
+				switch value[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				case 'r':
+					sb.WriteByte('\r')
+				case '"', '\\':
+					sb.WriteByte(value[i])
+				default:
+					sb.WriteByte('\\')
+					sb.WriteByte(value[i])
+				}
+				continue
+			}
+			sb.WriteByte(value[i])
+		}
+		if i >= len(value) {
+			return "", errors.New("双引号没有闭合")
+		}
+		result, rest = sb.String(), value[i+1:]
+	default:
+		// 没有引号时，空白字符后的 # 表示注释
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(value), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("引号后有多余的内容: %s", rest)
+	}
+	return result, nil
+}
+
+// ProcfileOptions 把 Procfile 中的进程类型转换为进程配置
+//
+// 与 foreman 一致，第i个进程类型(从0开始)的第n个实例(从1开始)的 PORT 为 起始端口+i*100+n-1，
+// 环境变量 PS 为实例名，例如 web.1。只有1个实例的进程使用进程类型作为进程名，多个实例时进程名为 web.1、web.2
+func ProcfileOptions(entries []ProcfileEntry, opts ...ProcfileOption) []process.Options {
+	cfg := procfileConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	basePort := cfg.basePort
+	if basePort <= 0 {
+		basePort = DefaultBasePort
+		if port, err := strconv.Atoi(cfg.env["PORT"]); err == nil && port > 0 {
+			basePort = port
+		}
+	}
+
+	var programs []process.Options
+	for i, entry := range entries {
+		count := 1
+		if n, ok := cfg.formation[entry.Name]; ok {
+			count = n
+		}
+		for n := 1; n <= count; n++ {
+			instance := fmt.Sprintf("%s.%d", entry.Name, n)
+			opts := process.NewOptions(
+				process.WithDirectory(cfg.directory),
+				process.WithEnvironment(cfg.env),
+				process.WithEnvironment(map[string]string{
+					"PORT": strconv.Itoa(basePort + i*100 + n - 1),
+					"PS":   instance,
+				}),
+				// 与 foreman 一致，先发送 TERM，等待后强制结束
+				process.WithStopSignal("TERM"),
+			)
+			opts.Name = entry.Name
+			if count > 1 {
+				opts.Name = instance
+			}
+			shellCommand(&opts, entry.Command)
+			programs = append(programs, opts)
+		}
+	}
+	return programs
+}
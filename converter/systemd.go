@@ -0,0 +1,861 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darkit/process"
+	"github.com/darkit/process/config"
+	"github.com/darkit/process/signals"
+	"github.com/darkit/process/utils"
+)
+
+// 命名空间与 systemd 中创建该命名空间的配置项的对应关系
+var systemdNamespaces = []struct{ namespace, key string }{
+	{"mount", "PrivateMounts"},
+	{"net", "PrivateNetwork"},
+	{"ipc", "PrivateIPC"},
+	{"uts", "ProtectHostname"},
+}
+
+// 写入单元文件的辅助结构
+type unitWriter struct {
+	buf   bytes.Buffer
+	notes []string // 无法转换的配置
+}
+
+// 写入一个配置项，值中的 % 会转义为 %%
+func (that *unitWriter) set(key, value string) {
+	fmt.Fprintf(&that.buf, "%s=%s\n", key, strings.ReplaceAll(value, "%", "%%"))
+}
+
+// 写入一个命令行配置项，参数中的 $ 会转义为 $$
+func (that *unitWriter) exec(key, prefix string, args []string) {
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		words = append(words, unitQuote(strings.ReplaceAll(arg, "$", "$$")))
+	}
+	that.set(key, prefix+strings.Join(words, " "))
+}
+
+// 写入通过shell执行的钩子，执行失败时不中止的钩子使用 - 前缀
+func (that *unitWriter) hooks(key, stage string, hooks []process.Hook) {
+	for i, hook := range hooks {
+		if hook.Command == "" {
+			that.notes = append(that.notes, fmt.Sprintf("hooks.%s[%d]: Go函数钩子无法转换", stage, i))
+			continue
+		}
+		prefix := ""
+		if !hook.AbortOnFailure {
+			prefix = "-"
+		}
+		that.exec(key, prefix, []string{shell, "-c", hook.Command})
+	}
+}
+
+// SystemdUnit 把进程配置转换为 systemd 的 service 单元文件，
+// 没有对应 systemd 配置项的配置以注释的形式写在文件开头
+func SystemdUnit(opts process.Options) []byte {
+	w := &unitWriter{}
+
+	w.buf.WriteString("[Unit]\n")
+	w.set("Description", opts.Name)
+	w.set("After", "network.target")
+
+	w.buf.WriteString("\n[Service]\n")
+	w.set("Type", "simple")
+	w.exec("ExecStart", "", append([]string{opts.Command}, opts.Args...))
+	if opts.Directory != "" {
+		w.set("WorkingDirectory", opts.Directory)
+	}
+	if opts.User != "" {
+		user, group, _ := strings.Cut(opts.User, ":")
+		w.set("User", user)
+		if group != "" {
+			w.set("Group", group)
+		}
+	}
+	if len(opts.Groups) > 0 {
+		w.set("SupplementaryGroups", strings.Join(opts.Groups, " "))
+	}
+	if opts.Umask != "" {
+		w.set("UMask", opts.Umask)
+	}
+	if opts.Environment != nil {
+		env := opts.Environment.Map()
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			w.set("Environment", unitQuote(key+"="+env[key]))
+		}
+	}
+
+	switch opts.AutoReStart {
+	case process.AutoReStartTrue:
+		w.set("Restart", "always")
+	case process.AutoReStartUnexpected:
+		w.set("Restart", "on-failure")
+		var codes []string
+		for _, code := range opts.ExitCodes {
+			if code != 0 {
+				codes = append(codes, strconv.Itoa(code))
+			}
+		}
+		if len(codes) > 0 {
+			w.set("SuccessExitStatus", strings.Join(codes, " "))
+		}
+	default:
+		w.set("Restart", "no")
+	}
+	w.set("RestartSec", formatTimespan(effectiveDuration(opts.RestartPause, opts.RestartPauseDuration)))
+
+	writeStop(w, opts)
+	switch {
+	case opts.StopAsGroup || opts.StopAsTree:
+		w.set("KillMode", "control-group")
+	case opts.KillAsGroup:
+		w.set("KillMode", "mixed")
+	default:
+		w.set("KillMode", "process")
+	}
+
+	if opts.StdoutLogfile != "" {
+		w.set("StandardOutput", "append:"+opts.StdoutLogfile)
+	}
+	switch {
+	case opts.RedirectStderr:
+		// systemd 默认把标准错误写入标准输出
+	case opts.StderrLogfile != "":
+		w.set("StandardError", "append:"+opts.StderrLogfile)
+	default:
+		w.set("StandardError", "journal")
+	}
+
+	if opts.Nice != 0 {
+		w.set("Nice", strconv.Itoa(opts.Nice))
+	}
+	if opts.IOClass != "" {
+		w.set("IOSchedulingClass", opts.IOClass)
+		if opts.IOClass != "idle" {
+			w.set("IOSchedulingPriority", strconv.Itoa(opts.IOPriority))
+		}
+	}
+	if len(opts.CPUAffinity) > 0 {
+		cpus := make([]string, 0, len(opts.CPUAffinity))
+		for _, cpu := range opts.CPUAffinity {
+			cpus = append(cpus, strconv.Itoa(cpu))
+		}
+		w.set("CPUAffinity", strings.Join(cpus, " "))
+	}
+	if opts.OOMScoreAdj != 0 {
+		w.set("OOMScoreAdjust", strconv.Itoa(opts.OOMScoreAdj))
+	}
+
+	if iso := opts.Isolation; iso != nil {
+		if iso.Chroot != "" {
+			w.set("RootDirectory", iso.Chroot)
+		}
+		for _, ns := range iso.Namespaces {
+			i := slices.IndexFunc(systemdNamespaces, func(item struct{ namespace, key string }) bool { return item.namespace == ns })
+			if i < 0 {
+				w.notes = append(w.notes, fmt.Sprintf("isolation.namespaces: %s 命名空间无法转换", ns))
+				continue
+			}
+			w.set(systemdNamespaces[i].key, "yes")
+		}
+		if iso.NoNewPrivs {
+			w.set("NoNewPrivileges", "yes")
+		}
+		if len(iso.AmbientCaps) > 0 {
+			w.set("AmbientCapabilities", strings.Join(iso.AmbientCaps, " "))
+		}
+	}
+
+	w.hooks("ExecStartPre", "pre_start", opts.Hooks.PreStart)
+	w.hooks("ExecStartPost", "post_start", opts.Hooks.PostStart)
+	w.hooks("ExecStop", "pre_stop", opts.Hooks.PreStop)
+	w.hooks("ExecStopPost", "post_stop", opts.Hooks.PostStop)
+
+	if opts.AutoStart {
+		w.buf.WriteString("\n[Install]\n")
+		w.set("WantedBy", "multi-user.target")
+	}
+
+	if opts.PidFile != "" {
+		w.notes = append(w.notes, "pid_file: systemd 只在 Type=forking 时使用 PIDFile")
+	}
+	if opts.RestartWhenBinaryChanged {
+		w.notes = append(w.notes, "restart_when_binary_changed: systemd 不支持")
+	}
+	if opts.MemoryLimit > 0 || opts.CPULimit > 0 {
+		w.notes = append(w.notes, "memory_limit/cpu_limit: 超限重启没有对应的配置，可以使用 MemoryMax、CPUQuota 限制资源")
+	}
+
+	var out bytes.Buffer
+	if len(w.notes) > 0 {
+		out.WriteString("# 以下配置没有对应的 systemd 配置项，未转换:\n")
+		for _, note := range w.notes {
+			fmt.Fprintf(&out, "#   %s\n", note)
+		}
+		out.WriteString("\n")
+	}
+	out.Write(w.buf.Bytes())
+	return out.Bytes()
+}
+
+// 写入停止相关的配置，systemd 先执行 ExecStop，再发送 KillSignal，超过 TimeoutStopSec 后发送 SIGKILL
+func writeStop(w *unitWriter, opts process.Options) {
+	var steps []process.StopStep
+	if opts.StopPlan != nil {
+		steps = opts.StopPlan.Steps
+	} else {
+		wait := effectiveDuration(opts.StopWaitSecs, opts.StopWaitDuration)
+		for _, sig := range opts.StopSignal {
+			steps = append(steps, process.StopStep{Signal: sig, Wait: wait})
+		}
+	}
+
+	signal := ""
+	var timeout time.Duration
+	for i, step := range steps {
+		switch {
+		case signal != "":
+			// 第一个信号之后的步骤都在 TimeoutStopSec 内
+			timeout += step.Wait
+			if step.Signal != "" {
+				w.notes = append(w.notes, fmt.Sprintf("stop_signal: systemd 只支持一个停止信号，%s 未转换", step.Signal))
+			} else {
+				w.notes = append(w.notes, fmt.Sprintf("stop_plan.steps[%d]: 发送信号后的步骤无法转换", i))
+			}
+		case step.Signal != "":
+			signal = step.Signal
+			timeout = step.Wait
+		case step.Command != "":
+			w.exec("ExecStop", "-", []string{shell, "-c", step.Command})
+		default:
+			w.notes = append(w.notes, fmt.Sprintf("stop_plan.steps[%d]: HTTP请求无法转换", i))
+		}
+	}
+	if signal == "" {
+		// 没有停止信号时直接强制结束进程
+		w.set("KillSignal", "SIGKILL")
+		w.set("TimeoutStopSec", formatTimespan(effectiveDuration(opts.KillWaitSecs, opts.KillWaitDuration)))
+		return
+	}
+	signal = strings.ToUpper(signal)
+	if _, err := strconv.Atoi(signal); err != nil && !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	w.set("KillSignal", signal)
+	w.set("TimeoutStopSec", formatTimespan(timeout))
+}
+
+// LoadSystemdUnit 加载 systemd 的 service 单元文件，进程名为去掉 .service 后缀的文件名
+func LoadSystemdUnit(file string) (process.Options, []string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return process.Options{}, nil, err
+	}
+	return ParseSystemdUnit(data, file)
+}
+
+// unitEntry 单元文件中的一个配置项
+type unitEntry struct {
+	section string
+	key     string
+	value   string
+	line    int
+}
+
+// 单元文件的解析状态
+type unitParser struct {
+	file     string
+	unit     string // 单元名，例如 web.service
+	opts     *process.Options
+	env      map[string]string
+	exec     []string // ExecStart 的参数，环境变量在所有配置解析完后展开
+	expand   bool     // ExecStart 是否需要展开环境变量
+	line     int      // ExecStart 所在行号
+	warnings []string
+	errs     []error
+}
+
+// 记录警告
+func (that *unitParser) warn(entry unitEntry, format string, args ...any) {
+	that.warnings = append(that.warnings, (&config.Error{File: that.file, Line: entry.line, Field: entry.key, Err: fmt.Errorf(format, args...)}).Error())
+}
+
+// 记录错误
+func (that *unitParser) fail(entry unitEntry, err error) {
+	that.errs = append(that.errs, &config.Error{File: that.file, Line: entry.line, Field: entry.key, Err: err})
+}
+
+// ParseSystemdUnit 解析 systemd 的 service 单元文件，进程名为去掉 .service 后缀的文件名
+//
+// 只转换 [Service] 中有对应进程配置的配置项，未设置的配置项使用 systemd 的默认值，
+// 例如不自动重启、停止时向整个控制组发送 SIGTERM 并等待90秒。不支持的配置项作为警告返回
+func ParseSystemdUnit(data []byte, file string) (process.Options, []string, error) {
+	entries, err := parseUnit(data, file)
+	if err != nil {
+		return process.Options{}, nil, err
+	}
+	unit := filepath.Base(file)
+	opts := process.NewOptions(
+		process.WithName(strings.TrimSuffix(unit, ".service")),
+		process.WithAutoStart(false),
+		process.WithStartSecs(0),
+		process.WithAutoReStart(process.AutoReStartFalse),
+		process.WithRestartPauseDuration(100*time.Millisecond),
+		process.WithStopSignal("TERM"),
+		process.WithStopWaitSecs(90),
+		process.WithStopAsGroup(true),
+		process.WithKillAsGroup(true),
+		process.WithRedirectStderr(true),
+	)
+	p := &unitParser{file: file, unit: unit, opts: &opts, env: make(map[string]string)}
+	for _, entry := range entries {
+		switch entry.section {
+		case "Service":
+			value, ok := p.specifiers(entry)
+			if ok {
+				entry.value = value
+				p.service(entry)
+			}
+		case "Install":
+			if entry.key == "WantedBy" || entry.key == "RequiredBy" {
+				opts.AutoStart = true
+			}
+		}
+	}
+	if p.exec == nil {
+		p.errs = append(p.errs, &config.Error{File: file, Field: "ExecStart", Err: errors.New("缺少 ExecStart")})
+	} else {
+		if p.expand {
+			for i, arg := range p.exec {
+				p.exec[i] = os.Expand(arg, func(name string) string {
+					if name == "$" {
+						return "$"
+					}
+					val, ok := p.env[name]
+					if !ok {
+						p.warn(unitEntry{key: "ExecStart", line: p.line}, "环境变量 %s 未在单元文件中定义，替换为空字符串", name)
+					}
+					return val
+				})
+			}
+		}
+		opts.Command, opts.Args = p.exec[0], p.exec[1:]
+	}
+	opts.Environment.Sets(p.env)
+	if err := errors.Join(p.errs...); err != nil {
+		return process.Options{}, p.warnings, err
+	}
+	return opts, p.warnings, nil
+}
+
+// 替换值中的说明符，%n、%N、%p 替换为单元名，不支持的说明符返回 false
+func (that *unitParser) specifiers(entry unitEntry) (string, bool) {
+	if !strings.Contains(entry.value, "%") {
+		return entry.value, true
+	}
+	name := strings.TrimSuffix(that.unit, ".service")
+	var sb strings.Builder
+	for i := 0; i < len(entry.value); i++ {
+		if entry.value[i] != '%' {
+			sb.WriteByte(entry.value[i])
+			continue
+		}
+		if i++; i >= len(entry.value) {
+			that.fail(entry, errors.New("% 后缺少说明符"))
+			return "", false
+		}
+		switch entry.value[i] {
+		case '%':
+			sb.WriteByte('%')
+		case 'n':
+			sb.WriteString(that.unit)
+		case 'N', 'p':
+			sb.WriteString(name)
+		default:
+			that.warn(entry, "不支持说明符 %%%c，已忽略该配置项", entry.value[i])
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// 解析 [Service] 中的配置项
+func (that *unitParser) service(entry unitEntry) {
+	opts := that.opts
+	value := entry.value
+	var err error
+	switch entry.key {
+	case "Type":
+		if value != "simple" && value != "exec" {
+			that.warn(entry, "不支持 Type=%s，按 simple 处理", value)
+		}
+	case "ExecStart":
+		if value == "" {
+			that.exec = nil
+			return
+		}
+		if that.exec != nil {
+			that.fail(entry, errors.New("只支持一个 ExecStart"))
+			return
+		}
+		var prefix string
+		prefix, that.exec, err = that.parseExec(entry)
+		that.expand = !strings.Contains(prefix, ":")
+		that.line = entry.line
+	case "ExecStartPre", "ExecStartPost", "ExecStop", "ExecStopPost":
+		err = that.hook(entry)
+	case "WorkingDirectory":
+		opts.Directory = strings.TrimPrefix(value, "-")
+	case "User":
+		_, group, _ := strings.Cut(opts.User, ":")
+		opts.User = joinUser(value, group)
+	case "Group":
+		user, _, _ := strings.Cut(opts.User, ":")
+		opts.User = joinUser(user, value)
+	case "SupplementaryGroups":
+		var groups []string
+		if groups, err = splitUnitWords(value); err == nil {
+			opts.Groups = append(opts.Groups, groups...)
+			if value == "" {
+				opts.Groups = nil
+			}
+		}
+	case "UMask":
+		opts.Umask = value
+	case "Environment":
+		if value == "" {
+			clear(that.env)
+			return
+		}
+		var words []string
+		if words, err = splitUnitWords(value); err == nil {
+			for _, word := range words {
+				key, val, ok := strings.Cut(word, "=")
+				if !ok || !envKey.MatchString(key) {
+					err = fmt.Errorf("无效的环境变量: %s", word)
+					break
+				}
+				that.env[key] = val
+			}
+		}
+	case "EnvironmentFile":
+		file, optional := strings.CutPrefix(value, "-")
+		var data []byte
+		if data, err = os.ReadFile(file); err != nil {
+			if optional && errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+			break
+		}
+		var env map[string]string
+		if env, err = ParseEnv(data, file); err == nil {
+			for key, val := range env {
+				that.env[key] = val
+			}
+		}
+	case "Restart":
+		switch value {
+		case "no":
+			opts.AutoReStart = process.AutoReStartFalse
+		case "always":
+			opts.AutoReStart = process.AutoReStartTrue
+		case "on-failure":
+			opts.AutoReStart = process.AutoReStartUnexpected
+		case "on-success", "on-abnormal", "on-abort", "on-watchdog":
+			opts.AutoReStart = process.AutoReStartUnexpected
+			that.warn(entry, "Restart=%s 按 on-failure 处理", value)
+		default:
+			err = fmt.Errorf("无效的值: %s", value)
+		}
+	case "RestartSec":
+		var d time.Duration
+		if d, err = parseTimespan(value); err == nil {
+			process.WithRestartPauseDuration(d)(opts)
+		}
+	case "SuccessExitStatus":
+		var words []string
+		if words, err = splitUnitWords(value); err == nil {
+			codes := []int{0}
+			for _, word := range words {
+				code, e := strconv.Atoi(word)
+				if e != nil {
+					that.warn(entry, "不支持信号 %s，已忽略", word)
+					continue
+				}
+				codes = append(codes, code)
+			}
+			opts.ExitCodes = codes
+		}
+	case "KillSignal":
+		if _, err = signals.ParseSignal(value); err == nil {
+			opts.StopSignal = []string{strings.TrimPrefix(strings.ToUpper(value), "SIG")}
+		}
+	case "TimeoutStopSec", "TimeoutSec":
+		var d time.Duration
+		if d, err = parseTimespan(value); err == nil {
+			process.WithStopWaitDuration(d)(opts)
+		}
+	case "KillMode":
+		switch value {
+		case "control-group":
+			opts.StopAsGroup, opts.KillAsGroup = true, true
+		case "mixed":
+			opts.StopAsGroup, opts.KillAsGroup = false, true
+		case "process":
+			opts.StopAsGroup, opts.KillAsGroup = false, false
+		default:
+			err = fmt.Errorf("不支持的值: %s", value)
+		}
+	case "StandardOutput":
+		opts.StdoutLogfile = that.output(entry)
+	case "StandardError":
+		opts.StderrLogfile = that.output(entry)
+		opts.RedirectStderr = value == "inherit"
+	case "Nice":
+		opts.Nice, err = parseInt(value)
+	case "IOSchedulingClass":
+		opts.IOClass = map[string]string{"1": "realtime", "2": "best-effort", "3": "idle"}[value]
+		if opts.IOClass == "" && value != "" && value != "0" && value != "none" {
+			opts.IOClass = value
+		}
+	case "IOSchedulingPriority":
+		opts.IOPriority, err = parseInt(value)
+	case "CPUAffinity":
+		var cpus []int
+		if cpus, err = parseCPUList(value); err == nil {
+			opts.CPUAffinity = append(opts.CPUAffinity, cpus...)
+			if value == "" {
+				opts.CPUAffinity = nil
+			}
+		}
+	case "OOMScoreAdjust":
+		opts.OOMScoreAdj, err = parseInt(value)
+	case "RootDirectory":
+		that.isolation().Chroot = value
+	case "NoNewPrivileges":
+		that.isolation().NoNewPrivs, err = parseUnitBool(value)
+	case "AmbientCapabilities":
+		var caps []string
+		if caps, err = splitUnitWords(value); err == nil {
+			iso := that.isolation()
+			iso.AmbientCaps = append(iso.AmbientCaps, caps...)
+		}
+	case "PrivateMounts", "PrivateNetwork", "PrivateIPC", "ProtectHostname":
+		var enabled bool
+		if enabled, err = parseUnitBool(value); err == nil && enabled {
+			i := slices.IndexFunc(systemdNamespaces, func(item struct{ namespace, key string }) bool { return item.key == entry.key })
+			iso := that.isolation()
+			if !slices.Contains(iso.Namespaces, systemdNamespaces[i].namespace) {
+				iso.Namespaces = append(iso.Namespaces, systemdNamespaces[i].namespace)
+			}
+		}
+	default:
+		that.warn(entry, "不支持的配置项，已忽略")
+	}
+	if err != nil {
+		that.fail(entry, err)
+	}
+}
+
+// 获取进程的隔离配置，未设置时创建
+func (that *unitParser) isolation() *process.Isolation {
+	if that.opts.Isolation == nil {
+		that.opts.Isolation = &process.Isolation{}
+	}
+	return that.opts.Isolation
+}
+
+// 解析 StandardOutput、StandardError，写入文件时返回文件路径
+func (that *unitParser) output(entry unitEntry) string {
+	for _, prefix := range []string{"append:", "file:", "truncate:"} {
+		if file, ok := strings.CutPrefix(entry.value, prefix); ok {
+			if prefix != "append:" {
+				that.warn(entry, "%s 按 append: 处理", strings.TrimSuffix(prefix, ":"))
+			}
+			return file
+		}
+	}
+	switch entry.value {
+	case "", "inherit", "journal", "null", "kmsg", "journal+console", "kmsg+console":
+	default:
+		that.warn(entry, "不支持输出到 %s，已忽略", entry.value)
+	}
+	return ""
+}
+
+// 解析命令行，返回前缀和参数，参数中的 $$ 在展开环境变量时还原
+func (that *unitParser) parseExec(entry unitEntry) (string, []string, error) {
+	value := entry.value
+	prefix := value[:len(value)-len(strings.TrimLeft(value, "-@:+!"))]
+	words, err := splitUnitWords(value[len(prefix):])
+	if err != nil {
+		return "", nil, err
+	}
+	if len(words) == 0 {
+		return "", nil, errors.New("缺少命令")
+	}
+	if strings.Contains(prefix, "@") {
+		// 第二个参数是进程的 argv[0]，进程配置不支持设置
+		if len(words) < 2 {
+			return "", nil, errors.New("使用 @ 前缀时缺少 argv[0]")
+		}
+		that.warn(entry, "不支持设置 argv[0]，已忽略 %s", words[1])
+		words = append(words[:1], words[2:]...)
+	}
+	if strings.ContainsAny(prefix, "+!") {
+		that.warn(entry, "不支持特权执行前缀 %s，已忽略", prefix)
+	}
+	return prefix, words, nil
+}
+
+// 解析 ExecStartPre 等命令，转换为钩子
+func (that *unitParser) hook(entry unitEntry) error {
+	hooks := &that.opts.Hooks
+	stage := map[string]*[]process.Hook{
+		"ExecStartPre":  &hooks.PreStart,
+		"ExecStartPost": &hooks.PostStart,
+		"ExecStop":      &hooks.PreStop,
+		"ExecStopPost":  &hooks.PostStop,
+	}[entry.key]
+	if entry.value == "" {
+		*stage = nil
+		return nil
+	}
+	prefix, words, err := that.parseExec(entry)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(prefix, ":") {
+		for i, word := range words {
+			words[i] = strings.ReplaceAll(word, "$$", "$")
+		}
+	}
+	command := utils.JoinCommand(words)
+	if len(words) == 3 && words[0] == shell && words[1] == "-c" {
+		command = words[2]
+	}
+	*stage = append(*stage, process.Hook{Command: command, AbortOnFailure: !strings.Contains(prefix, "-")})
+	return nil
+}
+
+// 组合用户和用户组
+func joinUser(user, group string) string {
+	if group == "" {
+		return user
+	}
+	return user + ":" + group
+}
+
+// 解析单元文件，支持 # 和 ; 开头的注释以及行尾 \ 的续行
+func parseUnit(data []byte, file string) ([]unitEntry, error) {
+	var entries []unitEntry
+	var errs []error
+	section := ""
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		start := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			next := strings.TrimSpace(lines[i])
+			if next != "" && (next[0] == '#' || next[0] == ';') {
+				next = `\`
+			}
+			line = strings.TrimSuffix(line, `\`) + " " + next
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(line, `\`))
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				errs = append(errs, &config.Error{File: file, Line: start, Err: fmt.Errorf("无效的段: %s", line)})
+				continue
+			}
+			section = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			errs = append(errs, &config.Error{File: file, Line: start, Err: errors.New("无效的格式，应为 Key=Value")})
+			continue
+		}
+		if section == "" {
+			errs = append(errs, &config.Error{File: file, Line: start, Field: strings.TrimSpace(key), Err: errors.New("配置项不在任何段中")})
+			continue
+		}
+		entries = append(entries, unitEntry{section: section, key: strings.TrimSpace(key), value: strings.TrimSpace(value), line: start})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// 按 systemd 的规则拆分单词，支持单引号、双引号和反斜杠转义
+func splitUnitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i++; i >= len(s) {
+				return nil, errors.New("行尾的反斜杠没有转义任何字符")
+			}
+			switch s[i] {
+			case 'n':
+				word.WriteByte('\n')
+			case 't':
+				word.WriteByte('\t')
+			case 'r':
+				word.WriteByte('\r')
+			default:
+				word.WriteByte(s[i])
+			}
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("引号没有闭合")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// 按 systemd 的规则引用单词，只包含普通字符的单词保持原样
+func unitQuote(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n\r\"'\\") {
+		return word
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(word) + `"`
+}
+
+// 解析整数
+func parseInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("无效的整数: %q", value)
+	}
+	return n, nil
+}
+
+// 解析 systemd 的布尔值
+func parseUnitBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true, nil
+	case "0", "no", "n", "false", "f", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的布尔值: %s", value)
+}
+
+// 解析 CPU 列表，支持空白或逗号分隔以及 0-3 形式的范围
+func parseCPUList(value string) ([]int, error) {
+	var cpus []int
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+		first, last, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("无效的CPU: %s", item)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("无效的CPU范围: %s", item)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// systemd 时间单位
+var timespanUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"": time.Second, "s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// 解析 systemd 的时间，例如 90、1min 30s、500ms，没有单位时表示秒
+func parseTimespan(value string) (time.Duration, error) {
+	if value == "infinity" {
+		return 0, errors.New("不支持 infinity")
+	}
+	var total time.Duration
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, errors.New("时间不能为空")
+	}
+	for s != "" {
+		n := len(s) - len(strings.TrimLeft(s, "0123456789."))
+		num, err := strconv.ParseFloat(s[:n], 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间: %s", value)
+		}
+		s = strings.TrimLeft(s[n:], " ")
+		u := len(s) - len(strings.TrimLeft(s, "abcdefghijklmnopqrstuvwxyz"))
+		unit, ok := timespanUnits[s[:u]]
+		if !ok {
+			return 0, fmt.Errorf("无效的时间单位: %s", s[:u])
+		}
+		total += time.Duration(num * float64(unit))
+		s = strings.TrimLeft(s[u:], " ")
+	}
+	return total, nil
+}
+
+// 把时长转换为 systemd 的时间，整秒时只写秒数
+func formatTimespan(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10)
+	case d%time.Millisecond == 0:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	default:
+		return strconv.FormatInt(int64(d/time.Microsecond), 10) + "us"
+	}
+}
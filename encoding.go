@@ -0,0 +1,327 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/darkit/process/utils"
+)
+
+// 进程配置的序列化格式与声明式配置文件一致：键使用下划线分隔的小写名称，
+// 容量和时长序列化为易读的字符串，例如 "50MB"、"1m30s"，解析时也接受数值，
+// 容量的数值表示字节数，时长的数值表示秒数
+
+// jsonSize 容量，序列化为 "50MB" 这样的字符串
+type jsonSize int64
+
+// MarshalJSON 序列化为带单位的字符串，负数保持为数值
+func (s jsonSize) MarshalJSON() ([]byte, error) {
+	if s < 0 {
+		return []byte(strconv.FormatInt(int64(s), 10)), nil
+	}
+	return json.Marshal(utils.FormatSize(uint64(s)))
+}
+
+// UnmarshalJSON 解析带单位的字符串或字节数
+func (s *jsonSize) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("无效的容量: %s", data)
+		}
+		*s = jsonSize(n)
+		return nil
+	}
+	size, err := utils.ParseSize(text)
+	if err != nil {
+		return err
+	}
+	*s = jsonSize(size)
+	return nil
+}
+
+// jsonDuration 时长，序列化为 "1m30s" 这样的字符串
+type jsonDuration time.Duration
+
+// MarshalJSON 序列化为 time.Duration 的字符串格式
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON 解析时长字符串或秒数
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var n float64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("无效的时长: %s", data)
+		}
+		*d = jsonDuration(n * float64(time.Second))
+		return nil
+	}
+	duration, err := utils.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(duration)
+	return nil
+}
+
+// String 返回自动重启策略的名称
+func (a AutoReStart) String() string {
+	switch a {
+	case AutoReStartUnexpected:
+		return "unexpected"
+	case AutoReStartTrue:
+		return "true"
+	case AutoReStartFalse:
+		return "false"
+	default:
+		return fmt.Sprintf("AutoReStart(%d)", uint8(a))
+	}
+}
+
+// MarshalText 序列化为自动重启策略的名称：unexpected、true、false
+func (a AutoReStart) MarshalText() ([]byte, error) {
+	if a > AutoReStartFalse {
+		return nil, fmt.Errorf("无效的自动重启策略[%d]", uint8(a))
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText 解析自动重启策略，支持 unexpected、true/false、yes/no、on/off、1/0
+func (a *AutoReStart) UnmarshalText(text []byte) error {
+	switch strings.ToLower(strings.TrimSpace(string(text))) {
+	case "unexpected":
+		*a = AutoReStartUnexpected
+	case "true", "yes", "on", "1":
+		*a = AutoReStartTrue
+	case "false", "no", "off", "0":
+		*a = AutoReStartFalse
+	default:
+		return fmt.Errorf("无效的自动重启策略: %q", text)
+	}
+	return nil
+}
+
+// UnmarshalJSON 支持策略名称、布尔值以及 AutoReStart 的数值
+func (a *AutoReStart) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		return a.UnmarshalText([]byte(v))
+	case bool:
+		return a.UnmarshalText([]byte(strconv.FormatBool(v)))
+	case float64:
+		if v != float64(uint8(v)) || AutoReStart(v) > AutoReStartFalse {
+			return fmt.Errorf("无效的自动重启策略[%v]", v)
+		}
+		*a = AutoReStart(v)
+		return nil
+	default:
+		return fmt.Errorf("无效的自动重启策略: %s", data)
+	}
+}
+
+// optionsAlias 与 Options 字段相同但没有方法，避免序列化时递归调用
+type optionsAlias Options
+
+// optionsJSON 进程配置的序列化格式，容量和时长字段覆盖 Options 中的同名字段，
+// start_secs 等以秒为单位的配置项序列化为实际生效的时长，例如 "500ms"
+type optionsJSON struct {
+	*optionsAlias
+	StartSecs             jsonDuration `json:"start_secs"`
+	RestartPause          jsonDuration `json:"restart_pause"`
+	StopWaitSecs          jsonDuration `json:"stop_wait_secs"`
+	KillWaitSecs          jsonDuration `json:"kill_wait_secs"`
+	StdoutLogFileMaxBytes jsonSize     `json:"stdout_logfile_max_bytes"`
+	StderrLogFileMaxBytes jsonSize     `json:"stderr_logfile_max_bytes"`
+	StatsInterval         jsonDuration `json:"stats_interval"`
+	MemoryLimit           jsonSize     `json:"memory_limit"`
+	MemoryLimitDuration   jsonDuration `json:"memory_limit_duration"`
+	CPULimitDuration      jsonDuration `json:"cpu_limit_duration"`
+}
+
+// MarshalJSON 序列化进程配置，继承的文件和钩子中的Go函数不会被序列化
+func (that Options) MarshalJSON() ([]byte, error) {
+	alias := optionsAlias(that)
+	return json.Marshal(optionsJSON{
+		optionsAlias:          &alias,
+		StartSecs:             jsonDuration(that.startDuration()),
+		RestartPause:          jsonDuration(that.restartPauseDuration()),
+		StopWaitSecs:          jsonDuration(that.stopWaitDuration()),
+		KillWaitSecs:          jsonDuration(that.killWaitDuration()),
+		StdoutLogFileMaxBytes: jsonSize(that.StdoutLogFileMaxBytes),
+		StderrLogFileMaxBytes: jsonSize(that.StderrLogFileMaxBytes),
+		StatsInterval:         jsonDuration(that.StatsInterval),
+		MemoryLimit:           jsonSize(that.MemoryLimit),
+		MemoryLimitDuration:   jsonDuration(that.MemoryLimitDuration),
+		CPULimitDuration:      jsonDuration(that.CPULimitDuration),
+	})
+}
+
+// UnmarshalJSON 解析进程配置，缺少的配置项保持原值，因此可以先用 NewOptions 设置默认值再解析
+func (that *Options) UnmarshalJSON(data []byte) error {
+	wire := optionsJSON{
+		optionsAlias:          (*optionsAlias)(that),
+		StartSecs:             jsonDuration(that.startDuration()),
+		RestartPause:          jsonDuration(that.restartPauseDuration()),
+		StopWaitSecs:          jsonDuration(that.stopWaitDuration()),
+		KillWaitSecs:          jsonDuration(that.killWaitDuration()),
+		StdoutLogFileMaxBytes: jsonSize(that.StdoutLogFileMaxBytes),
+		StderrLogFileMaxBytes: jsonSize(that.StderrLogFileMaxBytes),
+		StatsInterval:         jsonDuration(that.StatsInterval),
+		MemoryLimit:           jsonSize(that.MemoryLimit),
+		MemoryLimitDuration:   jsonDuration(that.MemoryLimitDuration),
+		CPULimitDuration:      jsonDuration(that.CPULimitDuration),
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	setDuration(time.Duration(wire.StartSecs), &that.StartSecs, &that.StartDuration)
+	setDuration(time.Duration(wire.RestartPause), &that.RestartPause, &that.RestartPauseDuration)
+	setDuration(time.Duration(wire.StopWaitSecs), &that.StopWaitSecs, &that.StopWaitDuration)
+	setDuration(time.Duration(wire.KillWaitSecs), &that.KillWaitSecs, &that.KillWaitDuration)
+	that.StdoutLogFileMaxBytes = int(wire.StdoutLogFileMaxBytes)
+	that.StderrLogFileMaxBytes = int(wire.StderrLogFileMaxBytes)
+	that.StatsInterval = time.Duration(wire.StatsInterval)
+	that.MemoryLimit = uint64(wire.MemoryLimit)
+	that.MemoryLimitDuration = time.Duration(wire.MemoryLimitDuration)
+	that.CPULimitDuration = time.Duration(wire.CPULimitDuration)
+	that.ensureMaps()
+	return nil
+}
+
+// MarshalYAML 与 JSON 使用相同的键和格式
+func (that Options) MarshalYAML() (interface{}, error) {
+	return jsonToYAML(that)
+}
+
+// UnmarshalYAML 与 JSON 使用相同的键和格式
+func (that *Options) UnmarshalYAML(node *yaml.Node) error {
+	return yamlToJSON(node, that)
+}
+
+// stopStepAlias 与 StopStep 字段相同但没有方法
+type stopStepAlias StopStep
+
+// stopStepJSON 停止步骤的序列化格式
+type stopStepJSON struct {
+	*stopStepAlias
+	Timeout jsonDuration `json:"timeout"`
+	Wait    jsonDuration `json:"wait"`
+}
+
+// MarshalJSON 时长序列化为字符串
+func (that StopStep) MarshalJSON() ([]byte, error) {
+	alias := stopStepAlias(that)
+	return json.Marshal(stopStepJSON{stopStepAlias: &alias, Timeout: jsonDuration(that.Timeout), Wait: jsonDuration(that.Wait)})
+}
+
+// UnmarshalJSON 时长支持字符串和秒数
+func (that *StopStep) UnmarshalJSON(data []byte) error {
+	wire := stopStepJSON{stopStepAlias: (*stopStepAlias)(that), Timeout: jsonDuration(that.Timeout), Wait: jsonDuration(that.Wait)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	that.Timeout, that.Wait = time.Duration(wire.Timeout), time.Duration(wire.Wait)
+	return nil
+}
+
+// stopPlanAlias 与 StopPlan 字段相同但没有方法
+type stopPlanAlias StopPlan
+
+// stopPlanJSON 停止计划的序列化格式
+type stopPlanJSON struct {
+	*stopPlanAlias
+	KillTimeout jsonDuration `json:"kill_timeout"`
+}
+
+// MarshalJSON 时长序列化为字符串
+func (that StopPlan) MarshalJSON() ([]byte, error) {
+	alias := stopPlanAlias(that)
+	return json.Marshal(stopPlanJSON{stopPlanAlias: &alias, KillTimeout: jsonDuration(that.KillTimeout)})
+}
+
+// UnmarshalJSON 时长支持字符串和秒数
+func (that *StopPlan) UnmarshalJSON(data []byte) error {
+	wire := stopPlanJSON{stopPlanAlias: (*stopPlanAlias)(that), KillTimeout: jsonDuration(that.KillTimeout)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	that.KillTimeout = time.Duration(wire.KillTimeout)
+	return nil
+}
+
+// hookAlias 与 Hook 字段相同但没有方法
+type hookAlias Hook
+
+// hookJSON 钩子的序列化格式
+type hookJSON struct {
+	*hookAlias
+	Timeout jsonDuration `json:"timeout"`
+}
+
+// MarshalJSON 时长序列化为字符串，Go函数不会被序列化
+func (that Hook) MarshalJSON() ([]byte, error) {
+	alias := hookAlias(that)
+	return json.Marshal(hookJSON{hookAlias: &alias, Timeout: jsonDuration(that.Timeout)})
+}
+
+// UnmarshalJSON 时长支持字符串和秒数，与配置文件一致，只写命令时可以简写为字符串
+func (that *Hook) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &that.Command); err == nil {
+		return nil
+	}
+	wire := hookJSON{hookAlias: (*hookAlias)(that), Timeout: jsonDuration(that.Timeout)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	that.Timeout = time.Duration(wire.Timeout)
+	return nil
+}
+
+// 通过 JSON 把值转换为 YAML 节点，保持 JSON 中键的顺序
+func jsonToYAML(value any) (*yaml.Node, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	node := doc.Content[0]
+	resetStyle(node)
+	return node, nil
+}
+
+// 清除从 JSON 解析得到的引号和流式风格，输出为块风格的 YAML
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// 把 YAML 节点转换为 JSON 后解析
+func yamlToJSON(node *yaml.Node, target json.Unmarshaler) error {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return target.UnmarshalJSON(data)
+}
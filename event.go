@@ -0,0 +1,57 @@
+package process
+
+import (
+	"time"
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventLimitExceeded EventType = "limit_exceeded" // 进程资源使用超出限制
+	EventOrphanAdopted EventType = "orphan_adopted" // 接管了父进程已经退出的孤儿进程
+	EventOrphanReaped  EventType = "orphan_reaped"  // 回收了已经退出的孤儿进程
+	EventExited        EventType = "exited"         // 进程运行结束
+	EventFatal         EventType = "fatal"          // 进程启动失败且不再重试
+	EventRollback      EventType = "rollback"       // 进程的配置被自动回滚
+	EventRestartFailed EventType = "restart_failed" // 资源超限的进程没有按时停止，放弃重启
+)
+
+// Event 进程管理器产生的事件
+type Event struct {
+	Type     EventType `json:"type"`                // 事件类型
+	Process  string    `json:"process"`             // 进程名
+	Pid      int       `json:"pid"`                 // 进程pid
+	Message  string    `json:"message"`             // 事件描述
+	ExitCode int       `json:"exit_code,omitempty"` // 进程退出码，仅 exited 事件有效，被信号结束时为128+信号值
+	Time     time.Time `json:"time"`                // 事件发生时间
+}
+
+// EventListener 事件监听函数
+type EventListener func(event Event)
+
+// Subscribe 订阅进程管理器产生的事件，监听函数在独立的协程中按订阅顺序调用
+func (m *Manager) Subscribe(listener EventListener) {
+	m.listenerLock.Lock()
+	defer m.listenerLock.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// emit 发布事件
+func (m *Manager) emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	m.listenerLock.RLock()
+	listeners := make([]EventListener, len(m.listeners))
+	copy(listeners, m.listeners)
+	m.listenerLock.RUnlock()
+	if len(listeners) == 0 {
+		return
+	}
+	go func() {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}()
+}
@@ -1,3 +1,9 @@
 module github.com/darkit/process
 
 go 1.21.5
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	golang.org/x/sys v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -3,11 +3,15 @@ package handlers
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/darkit/process"
+	_ "github.com/darkit/process/config" // 注册配置导出格式
 )
 
 // Handler 是一个泛型接口，定义了所有处理方法
@@ -20,6 +24,12 @@ type Handler[T any] interface {
 	RestartProcess() T
 	GetStdoutLog() T
 	GetStderrLog() T
+	GetProcessStats() T
+	ApplyConfig() T
+	ExportConfig() T
+	UpdateProcess() T
+	ListRevisions() T
+	RollbackProcess() T
 }
 
 // ProcessHandler 是一个泛型结构体，实现了 Handler 接口
@@ -60,59 +70,25 @@ func (h *ProcessHandler[T]) ListProcesses() T {
 // CreateProcess 创建新进程
 func (h *ProcessHandler[T]) CreateProcess() T {
 	return h.warp(func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			Name          string `json:"name"`
-			Command       string `json:"command"`
-			Args          string `json:"args"`
-			Directory     string `json:"directory"`
-			User          string `json:"user"`
-			Environment   string `json:"environment"`
-			AutoStart     bool   `json:"autoStart"`
-			AutoRestart   int    `json:"autoRestart"`
-			StdoutLogfile string `json:"stdoutLogfile"`
-			StderrLogfile string `json:"stderrLogfile"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			errorResponse(w, http.StatusBadRequest, "参数错误")
+		// 请求体与配置文件中的进程配置格式一致，缺少的配置项使用默认值
+		opts := process.NewOptions()
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			errorResponse(w, http.StatusBadRequest, "参数错误: "+err.Error())
 			return
 		}
 
-		env := make(map[string]string)
-		if req.Environment != "" {
-			for _, line := range strings.Split(req.Environment, "\n") {
-				parts := strings.SplitN(line, "=", 2)
-				if len(parts) == 2 {
-					env[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-				}
-			}
-		}
-
-		args := []string{}
-		if req.Args != "" {
-			args = strings.Fields(req.Args)
-		}
-
-		proc := process.NewProcess(
-			process.WithName(req.Name),
-			process.WithCommand(req.Command),
-			process.WithArgs(args...),
-			process.WithDirectory(req.Directory),
-			process.WithUser(req.User),
-			process.WithEnvironment(env),
-			process.WithAutoStart(req.AutoStart),
-			process.WithAutoReStart(process.AutoReStart(req.AutoRestart)),
-			process.WithStdoutLog(req.StdoutLogfile, "50MB", 10),
-			process.WithStderrLog(req.StderrLogfile, "50MB", 10),
-		)
-
-		proc, err := h.manager.NewProcessByProcess(proc)
+		proc, err := h.manager.NewProcessByOptions(opts)
 		if err != nil {
+			var validationErr *process.ValidationError
+			if errors.As(err, &validationErr) {
+				validationResponse(w, validationErr)
+				return
+			}
 			errorResponse(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		if req.AutoStart {
+		if opts.AutoStart {
 			proc.Start(true)
 		}
 
@@ -243,6 +219,185 @@ func (h *ProcessHandler[T]) GetStderrLog() T {
 	})
 }
 
+// GetProcessStats 获取进程资源使用情况
+func (h *ProcessHandler[T]) GetProcessStats() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		proc := h.manager.Find(name)
+		if proc == nil {
+			errorResponse(w, http.StatusNotFound, "进程不存在")
+			return
+		}
+
+		stats, err := proc.Stats()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"data": stats,
+		})
+	})
+}
+
+// ApplyConfig 把进程配置调整为请求中的配置，dry_run=true 时只返回变更计划
+func (h *ProcessHandler[T]) ApplyConfig() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Programs []json.RawMessage `json:"programs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "参数错误")
+			return
+		}
+		// 每个进程的配置先设置默认值再解析，与创建进程一致
+		programs := make([]process.Options, len(req.Programs))
+		for i, data := range req.Programs {
+			programs[i] = process.NewOptions()
+			if err := json.Unmarshal(data, &programs[i]); err != nil {
+				errorResponse(w, http.StatusBadRequest, fmt.Sprintf("参数错误: programs[%d]: %v", i, err))
+				return
+			}
+		}
+
+		plan, err := h.manager.Apply(programs, applyOptions(r)...)
+		if err != nil && len(plan.Changes) == 0 {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, map[string]interface{}{
+				"code": -1,
+				"msg":  err.Error(),
+				"data": plan,
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"data": plan,
+		})
+	})
+}
+
+// ExportConfig 导出所有进程的配置，format 可选值：[yaml,json,ini]，默认yaml，敏感信息总是被替换
+func (h *ProcessHandler[T]) ExportConfig() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "yaml"
+		}
+		data, err := h.manager.ExportConfig(format)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		contentType := map[string]string{"yaml": "application/yaml", "json": "application/json"}[strings.ToLower(format)]
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
+
+// UpdateProcess 修改进程的配置，请求体中的配置项覆盖进程当前的配置，dry_run=true 时只返回变更，
+// 修改人通过请求头 X-Author 设置
+func (h *ProcessHandler[T]) UpdateProcess() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		proc := h.manager.Find(name)
+		if proc == nil {
+			errorResponse(w, http.StatusNotFound, "进程不存在")
+			return
+		}
+		opts := proc.Options()
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			errorResponse(w, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+		// 进程名不能通过修改配置更改
+		opts.Name = name
+
+		change, err := h.manager.UpdateProcess(opts, applyOptions(r)...)
+		if err != nil {
+			var validationErr *process.ValidationError
+			if errors.As(err, &validationErr) {
+				validationResponse(w, validationErr)
+				return
+			}
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"data": change,
+		})
+	})
+}
+
+// ListRevisions 获取进程的配置版本，配置中的敏感信息被替换
+func (h *ProcessHandler[T]) ListRevisions() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		revisions := h.manager.Revisions(name)
+		if len(revisions) == 0 {
+			errorResponse(w, http.StatusNotFound, "进程不存在")
+			return
+		}
+		for i := range revisions {
+			revisions[i].Options = revisions[i].Options.Redacted()
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"data": revisions,
+		})
+	})
+}
+
+// RollbackProcess 把进程的配置恢复为指定版本，dry_run=true 时只返回变更，修改人通过请求头 X-Author 设置
+func (h *ProcessHandler[T]) RollbackProcess() T {
+	return h.warp(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		revision, err := strconv.Atoi(r.URL.Query().Get("revision"))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "参数错误: revision")
+			return
+		}
+
+		change, err := h.manager.Rollback(name, revision, applyOptions(r)...)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"msg":  "回滚成功",
+			"data": change,
+		})
+	})
+}
+
+// applyOptions 从请求中读取配置变更的选项：查询参数 dry_run 和请求头 X-Author
+func applyOptions(r *http.Request) []process.ApplyOption {
+	var opts []process.ApplyOption
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		opts = append(opts, process.WithDryRun())
+	}
+	if author := r.Header.Get("X-Author"); author != "" {
+		opts = append(opts, process.WithAuthor(author))
+	}
+	return opts
+}
+
 // 读取文件最后几行
 func (h *ProcessHandler[T]) readLastLines(filename string, n int) (string, error) {
 	file, err := os.Open(filename)
@@ -285,10 +440,12 @@ func (h *ProcessHandler[T]) SetupRoutes() *http.ServeMux {
 
 	setupRoute := func(path string, handler func() T) {
 		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			h := handler()
-			if fn, ok := any(h).(func(http.ResponseWriter, *http.Request)); ok {
+			switch fn := any(handler()).(type) {
+			case func(http.ResponseWriter, *http.Request):
 				fn(w, r)
-			} else {
+			case http.Handler:
+				fn.ServeHTTP(w, r)
+			default:
 				errorResponse(w, http.StatusInternalServerError, "Handler type mismatch")
 			}
 		})
@@ -302,6 +459,12 @@ func (h *ProcessHandler[T]) SetupRoutes() *http.ServeMux {
 	setupRoute("/process/restart", h.RestartProcess)
 	setupRoute("/process/stdout", h.GetStdoutLog)
 	setupRoute("/process/stderr", h.GetStderrLog)
+	setupRoute("/process/stats", h.GetProcessStats)
+	setupRoute("/process/apply", h.ApplyConfig)
+	setupRoute("/process/export", h.ExportConfig)
+	setupRoute("/process/update", h.UpdateProcess)
+	setupRoute("/process/revisions", h.ListRevisions)
+	setupRoute("/process/rollback", h.RollbackProcess)
 
 	return mux
 }
@@ -313,6 +476,22 @@ func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// validationResponse 以400状态码返回配置校验错误，errors 中包含每个无效配置项的错误
+func validationResponse(w http.ResponseWriter, err *process.ValidationError) {
+	fields := make([]map[string]string, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		fields = append(fields, map[string]string{
+			"field": e.Field,
+			"error": e.Err.Error(),
+		})
+	}
+	jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+		"code":   -1,
+		"msg":    "配置无效",
+		"errors": fields,
+	})
+}
+
 // errorResponse is a helper function to send error responses in JSON format
 func errorResponse(w http.ResponseWriter, status int, message string) {
 	jsonResponse(w, status, map[string]interface{}{
@@ -356,6 +535,12 @@ func main() {
     mux.HandleFunc("POST /process/restart", HttpHandlers.RestartProcess())
     mux.HandleFunc("GET /process/stdout", HttpHandlers.GetStdoutLog())
     mux.HandleFunc("GET /process/stderr", HttpHandlers.GetStderrLog())
+    mux.HandleFunc("GET /process/stats", HttpHandlers.GetProcessStats())
+    mux.HandleFunc("POST /process/apply", HttpHandlers.ApplyConfig())
+    mux.HandleFunc("GET /process/export", HttpHandlers.ExportConfig())
+    mux.HandleFunc("POST /process/update", HttpHandlers.UpdateProcess())
+    mux.HandleFunc("GET /process/revisions", HttpHandlers.ListRevisions())
+    mux.HandleFunc("POST /process/rollback", HttpHandlers.RollbackProcess())
 
 	// 启动服务器
 	fmt.Println("Server is running on http://localhost:8080")
@@ -384,6 +569,12 @@ func main() {
 	r.POST("/process/restart", GinHandlers.RestartProcess())
 	r.GET("/process/stdout", GinHandlers.GetStdoutLog())
 	r.GET("/process/stderr", GinHandlers.GetStderrLog())
+	r.GET("/process/stats", GinHandlers.GetProcessStats())
+	r.POST("/process/apply", GinHandlers.ApplyConfig())
+	r.GET("/process/export", GinHandlers.ExportConfig())
+	r.POST("/process/update", GinHandlers.UpdateProcess())
+	r.GET("/process/revisions", GinHandlers.ListRevisions())
+	r.POST("/process/rollback", GinHandlers.RollbackProcess())
 
 	// 启动服务器
 	fmt.Println("Server is running on http://localhost:8080")
@@ -0,0 +1,208 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// defaultHookTimeout 钩子默认的超时时间
+const defaultHookTimeout = 30 * time.Second
+
+// HookStage 钩子的执行阶段
+type HookStage string
+
+const (
+	HookPreStart  HookStage = "pre_start"  // 进程启动前，执行失败时按启动失败处理
+	HookPostStart HookStage = "post_start" // 进程进入运行状态后，执行失败时停止进程
+	HookPreStop   HookStage = "pre_stop"   // 发送停止信号前，执行失败时中止停止，进程继续运行
+	HookPostStop  HookStage = "post_stop"  // 进程退出后，执行失败时只记录日志
+)
+
+// HookEvent 钩子执行时的上下文信息，命令钩子通过环境变量获取
+type HookEvent struct {
+	Stage    HookStage // 执行阶段，环境变量 PROCESS_HOOK
+	Process  string    // 进程名，环境变量 PROCESS_NAME
+	Pid      int       // 进程pid，进程未运行时为0，环境变量 PROCESS_PID
+	ExitCode int       // 进程退出码，仅 post_stop 阶段有效，环境变量 PROCESS_EXIT_CODE
+}
+
+// HookFunc Go函数形式的钩子
+type HookFunc func(ctx context.Context, event HookEvent) error
+
+// Hook 生命周期钩子，Command 和 Func 二选一，同时设置时先执行 Command
+type Hook struct {
+	Command        string        `json:"command" yaml:"command"`                   // 通过shell执行的命令，输出写入进程的日志
+	Func           HookFunc      `json:"-" yaml:"-"`                               // Go函数，无法持久化
+	Timeout        time.Duration `json:"timeout" yaml:"timeout"`                   // 超时时间，默认30秒
+	AbortOnFailure bool          `json:"abort_on_failure" yaml:"abort_on_failure"` // 执行失败时是否中止当前的状态转换，默认false，只记录日志
+}
+
+// Hooks 进程各个阶段的钩子，同一阶段的钩子按顺序执行
+type Hooks struct {
+	PreStart  []Hook `json:"pre_start,omitempty" yaml:"pre_start,omitempty"`   // 进程启动前
+	PostStart []Hook `json:"post_start,omitempty" yaml:"post_start,omitempty"` // 进程进入运行状态后
+	PreStop   []Hook `json:"pre_stop,omitempty" yaml:"pre_stop,omitempty"`     // 发送停止信号前
+	PostStop  []Hook `json:"post_stop,omitempty" yaml:"post_stop,omitempty"`   // 进程退出后
+}
+
+// 获取指定阶段的钩子
+func (that *Hooks) get(stage HookStage) []Hook {
+	switch stage {
+	case HookPreStart:
+		return that.PreStart
+	case HookPostStart:
+		return that.PostStart
+	case HookPreStop:
+		return that.PreStop
+	case HookPostStop:
+		return that.PostStop
+	default:
+		return nil
+	}
+}
+
+// WithHook 添加指定阶段的钩子
+func WithHook(stage HookStage, hook Hook) WithOption {
+	return func(options *Options) {
+		switch stage {
+		case HookPreStart:
+			options.Hooks.PreStart = append(options.Hooks.PreStart, hook)
+		case HookPostStart:
+			options.Hooks.PostStart = append(options.Hooks.PostStart, hook)
+		case HookPreStop:
+			options.Hooks.PreStop = append(options.Hooks.PreStop, hook)
+		case HookPostStop:
+			options.Hooks.PostStop = append(options.Hooks.PostStop, hook)
+		}
+	}
+}
+
+// 依次执行指定阶段的钩子
+// 设置了 AbortOnFailure 的钩子执行失败时停止执行后续钩子并返回错误，其他钩子执行失败只记录日志
+func (that *Process) runHooks(stage HookStage, event HookEvent) error {
+	hooks := that.option.Hooks.get(stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+	event.Stage = stage
+	event.Process = that.option.Name
+
+	stdout, stderr, closeLog := that.hookOutput(stage)
+	defer closeLog()
+
+	for i, hook := range hooks {
+		that.Manager.logger.Infof("执行进程[%s]的%s钩子[%d]", that.option.Name, stage, i)
+		if err := that.runHook(hook, event, stdout, stderr); err != nil {
+			if hook.AbortOnFailure {
+				return fmt.Errorf("%s钩子[%d]执行失败: %w", stage, i, err)
+			}
+			that.Manager.logger.Warnf("进程[%s]的%s钩子[%d]执行失败: %v", that.option.Name, stage, i, err)
+		}
+	}
+	return nil
+}
+
+// 执行单个钩子
+func (that *Process) runHook(hook Hook, event HookEvent, stdout, stderr io.Writer) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if hook.Command != "" {
+		cmd := exec.CommandContext(ctx, getShell(), append([]string{getShellOption()}, parseCommand(hook.Command)...)...)
+		cmd.Dir = that.option.Directory
+		cmd.Env = os.Environ()
+		if that.option.Environment != nil {
+			for k, v := range that.option.Environment.Map() {
+				cmd.Env = append(cmd.Env, k+"="+v)
+			}
+		}
+		cmd.Env = append(cmd.Env,
+			"PROCESS_NAME="+event.Process,
+			"PROCESS_HOOK="+string(event.Stage),
+			"PROCESS_PID="+strconv.Itoa(event.Pid),
+		)
+		if event.Stage == HookPostStop {
+			cmd.Env = append(cmd.Env, "PROCESS_EXIT_CODE="+strconv.Itoa(event.ExitCode))
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		// 超时后命令启动的子进程可能仍然持有输出管道，不再等待它们
+		cmd.WaitDelay = time.Second
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("执行超时: %w", ctx.Err())
+			}
+			return err
+		}
+	}
+	if hook.Func != nil {
+		done := make(chan error, 1)
+		go func() {
+			done <- hook.Func(ctx, event)
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return fmt.Errorf("执行超时: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// 获取钩子输出写入的日志
+// 进程运行期间直接写入进程正在使用的日志，启动前和退出后进程的日志没有打开，需要临时打开日志
+func (that *Process) hookOutput(stage HookStage) (stdout, stderr io.Writer, closeLog func()) {
+	if stage == HookPostStart || stage == HookPreStop {
+		that.lock.RLock()
+		stdout, stderr = that.stdoutLog, that.stderrLog
+		that.lock.RUnlock()
+		if stdout != nil && stderr != nil {
+			return stdout, stderr, func() {}
+		}
+	}
+	stdoutLog := that.createStdoutLogger()
+	stderrLog := stdoutLog
+	if !that.option.RedirectStderr {
+		stderrLog = that.createStderrLogger()
+	}
+	return stdoutLog, stderrLog, func() {
+		_ = stdoutLog.Close()
+		if stderrLog != stdoutLog {
+			_ = stderrLog.Close()
+		}
+	}
+}
+
+// 进程进入运行状态后执行钩子，钩子要求中止时停止进程
+func (that *Process) runPostStartHooks(pid int) {
+	err := that.runHooks(HookPostStart, HookEvent{Pid: pid})
+	if err == nil {
+		return
+	}
+	that.Manager.logger.Errorf("进程[%s]的%v, 停止进程", that.option.Name, err)
+	that.Stop(false)
+}
+
+// 进程退出后执行钩子，执行期间释放锁，调用方需要持有锁
+func (that *Process) runPostStopHooks(state *os.ProcessState) {
+	event := HookEvent{ExitCode: -1}
+	if state != nil {
+		event.Pid = state.Pid()
+		event.ExitCode = exitCodeOf(state)
+	}
+	that.lock.Unlock()
+	defer that.lock.Lock()
+	if err := that.runHooks(HookPostStop, event); err != nil {
+		that.Manager.logger.Warnf("进程[%s]的%v", that.option.Name, err)
+	}
+}
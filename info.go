@@ -2,6 +2,7 @@ package process
 
 import (
 	"fmt"
+	"os"
 	"syscall"
 	"time"
 
@@ -10,45 +11,79 @@ import (
 
 // Info 进程的运行状态
 type Info struct {
-	Name          string `json:"name"`
-	Description   string `json:"description"`
-	Start         int    `json:"start"`
-	Stop          int    `json:"stop"`
-	Now           int    `json:"now"`
-	State         int    `json:"state"`
-	StateName     string `json:"statename"`
-	SpawnErr      string `json:"spawnerr"`
-	ExitStatus    int    `json:"exitstatus"`
-	Logfile       string `json:"logfile"`
-	StdoutLogfile string `json:"stdout_logfile"`
-	StderrLogfile string `json:"stderr_logfile"`
-	Pid           int    `json:"pid"`
+	Name          string    `json:"name"`
+	CommandLine   string    `json:"command_line"`
+	Description   string    `json:"description"`
+	Start         int       `json:"start"`
+	Stop          int       `json:"stop"`
+	Now           int       `json:"now"`
+	State         int       `json:"state"`
+	StateName     string    `json:"statename"`
+	SpawnErr      string    `json:"spawnerr"`
+	ExitStatus    int       `json:"exitstatus"`
+	Logfile       string    `json:"logfile"`
+	StdoutLogfile string    `json:"stdout_logfile"`
+	StderrLogfile string    `json:"stderr_logfile"`
+	Pid           int       `json:"pid"`
+	Stats         *Stats    `json:"stats,omitempty"`
+	RestartReason string    `json:"restart_reason"`
+	Schedule      *Schedule `json:"schedule,omitempty"`
 }
 
 // GetProcessInfo 获取进程的详情
 func (that *Process) GetProcessInfo() *Info {
 	return &Info{
 		Name:          that.GetName(),
+		CommandLine:   that.GetCommandLine(),
 		Description:   that.GetDescription(),
 		Start:         int(that.GetStartTime().Unix()),
 		Stop:          int(that.GetStopTime().Unix()),
 		Now:           int(time.Now().Unix()),
 		State:         int(that.GetState()),
 		StateName:     that.GetState().String(),
-		SpawnErr:      "",
+		SpawnErr:      that.GetSpawnErr(),
 		ExitStatus:    that.GetExitStatus(),
 		Logfile:       that.GetStdoutLogfile(),
 		StdoutLogfile: that.GetStdoutLogfile(),
 		StderrLogfile: that.GetStderrLogfile(),
 		Pid:           that.Pid(),
+		Stats:         that.cachedStats(),
+		RestartReason: that.GetRestartReason(),
+		Schedule:      that.GetSchedule(),
 	}
 }
 
+// GetSchedule 获取进程实际生效的调度参数，进程未运行时返回nil
+func (that *Process) GetSchedule() *Schedule {
+	pid := that.runningPid()
+	if pid <= 0 {
+		return nil
+	}
+	return readSchedule(pid)
+}
+
 // GetName 获取进程名
 func (that *Process) GetName() string {
 	return that.option.Name
 }
 
+// GetCommandLine 获取进程的命令行，没有记录原始命令行时由启动命令和参数生成
+func (that *Process) GetCommandLine() string {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	if that.option.CommandLine != "" {
+		return that.option.CommandLine
+	}
+	return utils.JoinCommand(append([]string{that.option.Command}, that.option.Args...))
+}
+
+// Options 获取进程配置的副本，修改副本不会影响进程，修改配置请使用 Manager.Apply
+func (that *Process) Options() Options {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	return that.option.Clone()
+}
+
 // GetDescription 获取进程描述
 func (that *Process) GetDescription() string {
 	that.lock.RLock()
@@ -72,6 +107,13 @@ func (that *Process) GetDescription() string {
 	return ""
 }
 
+// GetSpawnErr 获取进程最近一次启动失败的原因
+func (that *Process) GetSpawnErr() string {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	return that.spawnErr
+}
+
 // GetState 获取进程状态
 func (that *Process) GetState() State {
 	return that.state
@@ -135,7 +177,7 @@ func (that *Process) GetStdoutLogfile() string {
 func (that *Process) GetStderrLogfile() string {
 	fileName := "/dev/null"
 	if len(that.option.StderrLogfile) > 0 {
-		fileName = that.option.StdoutLogfile
+		fileName = that.option.StderrLogfile
 	}
 	expandFile := utils.RealPath(fileName)
 	return expandFile
@@ -161,6 +203,14 @@ func (that *Process) getExitCode() (int, error) {
 	return -1, fmt.Errorf("no exit code")
 }
 
+// 把进程的退出状态转换为shell风格的退出码，被信号结束时为128+信号值
+func exitCodeOf(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
 // 进程的退出code值是否在设置中的codes列表中
 func (that *Process) inExitCodes(exitCode int) bool {
 	for _, code := range that.getExitCodes() {
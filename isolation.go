@@ -0,0 +1,144 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Isolation Linux 下的进程隔离配置，其他系统设置后会启动失败
+type Isolation struct {
+	Chroot      string   `json:"chroot" yaml:"chroot"`                                 // 切换进程的根目录，Command 和 Directory 都相对于新的根目录
+	Namespaces  []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`     // 为进程创建的命名空间，可选值：[mount,pid,net,uts,ipc,user]
+	UidMappings []IDMap  `json:"uid_mappings,omitempty" yaml:"uid_mappings,omitempty"` // user 命名空间中的uid映射，未设置时把当前用户映射为命名空间中的root
+	GidMappings []IDMap  `json:"gid_mappings,omitempty" yaml:"gid_mappings,omitempty"` // user 命名空间中的gid映射，未设置时把当前用户组映射为命名空间中的root
+	NoNewPrivs  bool     `json:"no_new_privs" yaml:"no_new_privs"`                     // 设置 no_new_privs，禁止进程通过 setuid 程序或文件能力获取新的权限
+	AmbientCaps []string `json:"ambient_caps,omitempty" yaml:"ambient_caps,omitempty"` // 进程的 ambient 能力集，例如 CAP_NET_BIND_SERVICE
+}
+
+// IDMap user 命名空间的id映射
+type IDMap struct {
+	ContainerID int `json:"container_id" yaml:"container_id"` // 命名空间中的起始id
+	HostID      int `json:"host_id" yaml:"host_id"`           // 宿主机上的起始id
+	Size        int `json:"size" yaml:"size"`                 // 映射的id数量
+}
+
+// 支持的命名空间
+var namespaceNames = []string{"mount", "pid", "net", "uts", "ipc", "user"}
+
+// 能力名称与编号的对应关系，参见 capabilities(7)
+var capabilities = map[string]uintptr{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// WithIsolation 设置进程的隔离配置，仅支持Linux
+func WithIsolation(opt Isolation) WithOption {
+	return func(options *Options) {
+		options.Isolation = &opt
+	}
+}
+
+// 是否开启了指定的命名空间
+func (that *Isolation) hasNamespace(name string) bool {
+	for _, ns := range that.Namespaces {
+		if strings.EqualFold(ns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 把能力名称转换为编号，名称可以省略 CAP_ 前缀
+func capabilityValue(name string) (uintptr, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "CAP_") {
+		name = "CAP_" + name
+	}
+	val, ok := capabilities[name]
+	return val, ok
+}
+
+// validate 检查隔离配置是否有效，以及与其他配置项是否冲突
+// user: 进程的运行用户
+func (that *Isolation) validate(user string) error {
+	var errs []error
+	for _, ns := range that.Namespaces {
+		found := false
+		for _, name := range namespaceNames {
+			if strings.EqualFold(ns, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("不支持的命名空间[%s]，可选值：%s", ns, strings.Join(namespaceNames, ",")))
+		}
+	}
+
+	userNS := that.hasNamespace("user")
+	if !userNS && (len(that.UidMappings) > 0 || len(that.GidMappings) > 0) {
+		errs = append(errs, errors.New("设置uid/gid映射时必须同时开启user命名空间"))
+	}
+	if userNS && user != "" {
+		errs = append(errs, errors.New("开启user命名空间时不能设置运行用户，请通过uid/gid映射指定命名空间中的用户"))
+	}
+	// 非root用户只能通过user命名空间创建其他命名空间
+	if !userNS && len(that.Namespaces) > 0 && os.Geteuid() != 0 {
+		errs = append(errs, errors.New("非root用户创建命名空间时必须同时开启user命名空间"))
+	}
+	if that.Chroot != "" && !userNS && os.Geteuid() != 0 {
+		errs = append(errs, errors.New("非root用户设置chroot时必须同时开启user命名空间"))
+	}
+	for _, mapping := range append(that.UidMappings, that.GidMappings...) {
+		if mapping.Size <= 0 || mapping.ContainerID < 0 || mapping.HostID < 0 {
+			errs = append(errs, fmt.Errorf("无效的id映射: %+v", mapping))
+		}
+	}
+	for _, c := range that.AmbientCaps {
+		if _, ok := capabilityValue(c); !ok {
+			errs = append(errs, fmt.Errorf("不支持的能力[%s]", c))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// prctl 的 PR_SET_NO_NEW_PRIVS 选项
+const prSetNoNewPrivs = 38
+
+// 命名空间名称与 clone 标志的对应关系
+var namespaceFlags = map[string]uintptr{
+	"mount": syscall.CLONE_NEWNS,
+	"pid":   syscall.CLONE_NEWPID,
+	"net":   syscall.CLONE_NEWNET,
+	"uts":   syscall.CLONE_NEWUTS,
+	"ipc":   syscall.CLONE_NEWIPC,
+	"user":  syscall.CLONE_NEWUSER,
+}
+
+// 设置进程的隔离配置
+func (that *Process) setIsolation(attr *syscall.SysProcAttr) error {
+	iso := that.option.Isolation
+	if iso == nil {
+		return nil
+	}
+	if err := iso.validate(that.option.User); err != nil {
+		return err
+	}
+
+	if iso.Chroot != "" {
+		if info, err := os.Stat(iso.Chroot); err != nil || !info.IsDir() {
+			return fmt.Errorf("chroot目录[%s]不存在", iso.Chroot)
+		}
+		attr.Chroot = iso.Chroot
+	}
+	for _, ns := range iso.Namespaces {
+		if flag, ok := namespaceFlags[strings.ToLower(ns)]; ok {
+			attr.Cloneflags |= flag
+		}
+	}
+	if iso.hasNamespace("user") {
+		attr.UidMappings = toSysProcIDMaps(iso.UidMappings, os.Getuid())
+		attr.GidMappings = toSysProcIDMaps(iso.GidMappings, os.Getgid())
+		// 非特权用户写入gid映射前必须禁用setgroups
+		attr.GidMappingsEnableSetgroups = os.Geteuid() == 0
+	}
+	for _, c := range iso.AmbientCaps {
+		val, _ := capabilityValue(c)
+		attr.AmbientCaps = append(attr.AmbientCaps, val)
+	}
+	return nil
+}
+
+// 转换id映射，未设置映射时把指定的id映射为命名空间中的root
+func toSysProcIDMaps(mappings []IDMap, defaultHostID int) []syscall.SysProcIDMap {
+	if len(mappings) == 0 {
+		return []syscall.SysProcIDMap{{ContainerID: 0, HostID: defaultHostID, Size: 1}}
+	}
+	result := make([]syscall.SysProcIDMap, 0, len(mappings))
+	for _, m := range mappings {
+		result = append(result, syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size})
+	}
+	return result
+}
+
+// 启动进程
+// no_new_privs、umask 以及nice值、IO优先级、CPU亲和性都可以设置为线程属性，fork 出的子进程会继承，
+// 所以需要在一个独立锁定的线程中设置后再启动进程，该线程不会解锁，协程退出后线程随之销毁，不会影响其他协程
+func (that *Process) startCommand() error {
+	iso := that.option.Isolation
+	noNewPrivs := iso != nil && iso.NoNewPrivs
+	if !noNewPrivs && !that.hasThreadSchedule() && that.option.Umask == "" {
+		return that.cmd.Start()
+	}
+
+	started := make(chan error, 1)
+	exited := make(chan struct{})
+	that.startThreadExited = exited
+	go func() {
+		runtime.LockOSThread()
+		if noNewPrivs {
+			if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+				started <- fmt.Errorf("设置no_new_privs失败: %w", errno)
+				return
+			}
+		}
+		if err := setThreadUmask(that.option.Umask); err != nil {
+			started <- err
+			return
+		}
+		// 调度参数设置失败时仍然启动进程
+		if err := that.setThreadSchedule(); err != nil {
+			that.Manager.logger.Warnf("设置程序[%s]的调度参数失败: %v", that.option.Name, err)
+		}
+		err := that.cmd.Start()
+		started <- err
+		if err != nil {
+			return
+		}
+		// Pdeathsig 在创建子进程的线程退出时就会触发，所以要保持该线程直到子进程退出
+		<-exited
+	}()
+	return <-started
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+	"syscall"
+)
+
+// 设置进程的隔离配置，非Linux系统不支持
+func (that *Process) setIsolation(_ *syscall.SysProcAttr) error {
+	if that.option.Isolation != nil {
+		return errors.New("当前系统不支持进程隔离配置")
+	}
+	return nil
+}
+
+// 启动进程
+func (that *Process) startCommand() error {
+	return withUmask(that.option.Umask, that.cmd.Start)
+}
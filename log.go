@@ -3,7 +3,10 @@ package process
 import (
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/darkit/process/proclog"
 )
@@ -52,7 +55,7 @@ func sprintf(format string, args ...interface{}) string {
 	return fmt.Sprintf(format, args...)
 }
 
-// 创建标准输出日志
+// 创建标准输出日志，进程的输出和运行期间执行的钩子的输出会并发写入，需要加锁
 func (that *Process) createStdoutLogger() proclog.Logger {
 	logFile := that.GetStdoutLogfile()
 	maxBytes := int64(that.option.StdoutLogFileMaxBytes)
@@ -64,10 +67,10 @@ func (that *Process) createStdoutLogger() proclog.Logger {
 		"pid":     strconv.Itoa(that.Pid()),
 	}
 
-	return proclog.NewLogger(that.GetName(), logFile, proclog.NewNullLocker(), maxBytes, backups, props)
+	return proclog.NewLogger(that.GetName(), logFile, &sync.Mutex{}, maxBytes, backups, props)
 }
 
-// 创建标准错误日志
+// 创建标准错误日志，进程的输出和运行期间执行的钩子的输出会并发写入，需要加锁
 func (that *Process) createStderrLogger() proclog.Logger {
 	logFile := that.GetStderrLogfile()
 	maxBytes := int64(that.option.StderrLogFileMaxBytes)
@@ -79,5 +82,55 @@ func (that *Process) createStderrLogger() proclog.Logger {
 		"pid":     strconv.Itoa(that.Pid()),
 	}
 
-	return proclog.NewLogger(that.GetName(), logFile, proclog.NewNullLocker(), maxBytes, backups, props)
+	return proclog.NewLogger(that.GetName(), logFile, &sync.Mutex{}, maxBytes, backups, props)
+}
+
+// 持久化模式下子进程在管理器退出后继续运行，输出不能经过管理器的管道，否则管理器退出后子进程写入时会收到 SIGPIPE，
+// 所以直接把日志文件交给子进程：只配置了一个普通文件时追加写入该文件，/dev/stdout、/dev/stderr 继承管理器的输出，
+// syslog 和多个日志文件无法由子进程直接写入，输出被丢弃
+func openDirectLog(logFile string) (*os.File, error) {
+	files := splitLogFiles(logFile)
+	if len(files) == 1 {
+		switch file := files[0]; {
+		case file == "/dev/stdout":
+			return os.Stdout, nil
+		case file == "/dev/stderr":
+			return os.Stderr, nil
+		case isRegularLogFile(file):
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				return nil, fmt.Errorf("创建日志目录失败: %w", err)
+			}
+			return os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		}
+	}
+	return os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+}
+
+// 持久化模式下设置直接交给子进程的日志文件
+func (that *Process) setDirectLog() error {
+	stdout, err := openDirectLog(that.GetStdoutLogfile())
+	if err != nil {
+		return err
+	}
+	stderr := stdout
+	if !that.option.RedirectStderr {
+		if stderr, err = openDirectLog(that.GetStderrLogfile()); err != nil {
+			_ = stdout.Close()
+			return err
+		}
+	}
+	that.cmd.Stdout, that.cmd.Stderr = stdout, stderr
+	that.directLogs = []*os.File{stdout, stderr}
+	return nil
+}
+
+// 子进程启动后关闭管理器持有的日志文件，子进程已经继承了它们
+func (that *Process) closeDirectLogs() {
+	for i, file := range that.directLogs {
+		if file == os.Stdout || file == os.Stderr || (i > 0 && file == that.directLogs[0]) {
+			continue
+		}
+		_ = file.Close()
+	}
+	that.directLogs = nil
 }
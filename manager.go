@@ -2,12 +2,38 @@ package process
 
 import (
 	"fmt"
+	"os"
 	"sync"
+	"time"
 )
 
 type Manager struct {
 	processes sync.Map
 	logger    Logger
+
+	listenerLock sync.RWMutex    // 事件监听锁
+	listeners    []EventListener // 事件监听函数列表
+
+	pidRecordLock sync.Mutex           // pid记录锁
+	pidRecordFile string               // pid记录文件，为空表示未开启遗留进程清理
+	pidRecords    map[string]pidRecord // 进程名对应的pid记录
+
+	persistLock sync.Mutex // 持久化配置锁
+	persistDir  string     // 持久化目录，为空表示未开启持久化模式
+
+	spawnLock sync.RWMutex     // 进程启动锁，子进程回收时需要等待正在启动的进程记录pid
+	childLock sync.Mutex       // 子进程记录锁
+	children  map[int]struct{} // 管理器启动的子进程pid
+	subreaper bool             // 是否已开启子进程收割模式
+
+	pidFile *os.File // 管理器的pid文件，持有文件锁保证只有一个实例在运行
+
+	applyLock sync.Mutex // 配置变更锁，Apply、UpdateProcess 和 Rollback 串行执行
+
+	revisionLock   sync.Mutex            // 配置版本锁
+	revisions      map[string][]Revision // 进程名对应的配置版本
+	rollingBack    map[string]bool       // 正在自动回滚的进程
+	rollbackWindow time.Duration         // 自动回滚的时间窗口，为0表示未开启自动回滚
 }
 
 // NewManager 创建进程管理器
@@ -31,6 +57,9 @@ func (m *Manager) NewProcess(opts ...WithOption) (*Process, error) {
 	if len(options.Name) == 0 {
 		options.Name = options.Command
 	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
 
 	if _, exists := m.processes.Load(options.Name); exists {
 		return nil, fmt.Errorf("进程[%s]已存在", options.Name)
@@ -44,6 +73,7 @@ func (m *Manager) NewProcess(opts ...WithOption) (*Process, error) {
 	}
 
 	m.processes.Store(options.Name, proc)
+	m.recordRevision(options, "", nil, 0)
 	m.logger.Infof("创建进程: %s", proc.GetName())
 
 	return proc, nil
@@ -52,6 +82,14 @@ func (m *Manager) NewProcess(opts ...WithOption) (*Process, error) {
 // NewProcessByOptions 创建进程
 // opts: 配置对象
 func (m *Manager) NewProcessByOptions(opts Options) (*Process, error) {
+	return m.newProcessByOptions(opts, "", 0)
+}
+
+// 创建进程并记录配置版本，rollback 为回滚的原版本号
+func (m *Manager) newProcessByOptions(opts Options, author string, rollback int) (*Process, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
 	if _, exists := m.processes.Load(opts.Name); exists {
 		return nil, fmt.Errorf("进程[%s]已存在", opts.Name)
 	}
@@ -59,6 +97,7 @@ func (m *Manager) NewProcessByOptions(opts Options) (*Process, error) {
 	proc := NewProcessByOptions(opts)
 	proc.Manager = m
 	m.processes.Store(opts.Name, proc)
+	m.recordRevision(opts, author, nil, rollback)
 
 	return proc, nil
 }
@@ -66,11 +105,15 @@ func (m *Manager) NewProcessByOptions(opts Options) (*Process, error) {
 // NewProcessByProcess 创建进程
 // proc: Process对象
 func (m *Manager) NewProcessByProcess(proc *Process) (*Process, error) {
+	if err := proc.option.Validate(); err != nil {
+		return nil, err
+	}
 	if _, found := m.processes.Load(proc.GetName()); found {
 		return nil, fmt.Errorf("进程[%s]已存在", proc.GetName())
 	}
 	proc.Manager = m
 	m.processes.Store(proc.GetName(), proc)
+	m.recordRevision(proc.option, "", nil, 0)
 	m.logger.Infof("创建进程: %s", proc.GetName())
 	return proc, nil
 }
@@ -80,11 +123,18 @@ func (m *Manager) NewProcessByProcess(proc *Process) (*Process, error) {
 // environment: 环境变量
 func (m *Manager) NewProcessCmd(cmd string, environment map[string]string) (*Process, error) {
 	p := NewProcessCmd(cmd, environment)
+	if p.option.Name == "" {
+		p.option.Name = cmd
+	}
+	if err := p.option.Validate(); err != nil {
+		return nil, err
+	}
 	if _, exists := m.processes.Load(p.GetName()); exists {
 		return nil, fmt.Errorf("进程[%s]已存在", p.GetName())
 	}
 	p.Manager = m
 	m.processes.Store(p.GetName(), p)
+	m.recordRevision(p.option, "", nil, 0)
 	return p, nil
 }
 
@@ -2,6 +2,9 @@ package process
 
 import (
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/darkit/process/utils"
 )
@@ -17,36 +20,61 @@ type AutoReStart uint8
 
 // Options 进程配置选项
 type Options struct {
-	Name         string      // 进程名称
-	Command      string      // 启动命令
-	Args         []string    // 启动参数
-	Directory    string      // 进程运行目录
-	AutoStart    bool        // 启动的时候自动该进程启动
-	StartSecs    int         // 启动10秒后没有异常退出，就表示进程正常启动了，默认为1秒
-	AutoReStart  AutoReStart // 程序退出后自动重启,可选值：[unexpected,true,false]，默认为unexpected，表示进程意外杀死后才重启
-	ExitCodes    []int       // 进程退出的code值
-	StartRetries int         // 启动失败自动重试次数，默认是3
-	RestartPause int         // 进程重启间隔秒数，默认是0，表示不间隔
-	User         string      // 用哪个用户启动进程，默认是父进程的所属用户
-	Priority     int         // 进程启动优先级，默认999，值小的优先启动
-
-	StdoutLogfile         string // 日志文件，不存在时 supervisord 会自动创建日志文件）
-	StdoutLogFileMaxBytes int    // stdout 日志文件大小，默认50MB
-	StdoutLogFileBackups  int    // stdout 日志文件备份数，默认是10
-	RedirectStderr        bool   // 把stderr重定向到stdout，默认false
-	StderrLogfile         string // 日志文件，进程启动后的标准错误写入该文件
-	StderrLogFileMaxBytes int    // stderr 日志文件大小，默认50MB
-	StderrLogFileBackups  int    // stderr 日志文件备份数，默认是10
-
-	StopAsGroup              bool             // 默认为false,进程被杀死时，是否向这个进程组发送stop信号，包括子进程
-	KillAsGroup              bool             // 默认为false，向进程组发送kill信号，包括子进程
-	StopSignal               []string         // 结束进程发送的信号
-	StopWaitSecs             int              // 发送结束进程的信号后等待的秒数
-	KillWaitSecs             int              // 强杀进程等待秒数
-	Environment              *utils.StrStrMap // 环境变量
-	RestartWhenBinaryChanged bool             // 当进程的二进制文件有修改，是否需要重启,默认false
-	ExtraFiles               []*os.File       // 继承主进程已经打开的文件列表
-	Extend                   *utils.AnyAnyMap // 扩展参数
+	Name                 string        `json:"name" yaml:"name"`                                     // 进程名称
+	Command              string        `json:"command" yaml:"command"`                               // 启动命令
+	Args                 []string      `json:"args,omitempty" yaml:"args,omitempty"`                 // 启动参数
+	CommandLine          string        `json:"command_line,omitempty" yaml:"command_line,omitempty"` // 通过命令行创建进程时的原始命令行，仅用于展示
+	Directory            string        `json:"directory" yaml:"directory"`                           // 进程运行目录
+	PidFile              string        `json:"pid_file" yaml:"pid_file"`                             // pid文件，进程进入运行状态时写入，退出后删除，默认不写入
+	AutoStart            bool          `json:"auto_start" yaml:"auto_start"`                         // 启动的时候自动该进程启动
+	StartSecs            int           `json:"start_secs" yaml:"start_secs"`                         // 启动10秒后没有异常退出，就表示进程正常启动了，默认为1秒
+	StartDuration        time.Duration `json:"-" yaml:"-"`                                           // 启动后需要稳定运行的时长，不为0时优先于 StartSecs，用于小于1秒的精度
+	AutoReStart          AutoReStart   `json:"auto_restart" yaml:"auto_restart"`                     // 程序退出后自动重启,可选值：[unexpected,true,false]，默认为unexpected，表示进程意外杀死后才重启
+	ExitCodes            []int         `json:"exit_codes,omitempty" yaml:"exit_codes,omitempty"`     // 进程退出的code值
+	StartRetries         int           `json:"start_retries" yaml:"start_retries"`                   // 启动失败自动重试次数，默认是3
+	RestartPause         int           `json:"restart_pause" yaml:"restart_pause"`                   // 进程重启间隔秒数，默认是0，表示不间隔
+	RestartPauseDuration time.Duration `json:"-" yaml:"-"`                                           // 进程重启间隔，不为0时优先于 RestartPause，用于小于1秒的精度
+	User                 string        `json:"user" yaml:"user"`                                     // 用哪个用户启动进程，默认是父进程的所属用户
+	Groups               []string      `json:"groups,omitempty" yaml:"groups,omitempty"`             // 额外加入的用户组(组名或gid)，切换用户时还会加入该用户所属的所有附属组
+	Umask                string        `json:"umask" yaml:"umask"`                                   // 进程的umask，八进制字符串，例如"022"，默认继承父进程
+	Priority             int           `json:"priority" yaml:"priority"`                             // 进程启动优先级，默认999，值小的优先启动
+	Nice                 int           `json:"nice" yaml:"nice"`                                     // 进程的nice值，-20到19，默认是0，表示继承父进程，仅支持Linux
+	IOClass              string        `json:"io_class" yaml:"io_class"`                             // IO调度类型，可选值：[realtime,best-effort,idle]，默认继承父进程，仅支持Linux
+	IOPriority           int           `json:"io_priority" yaml:"io_priority"`                       // IO优先级，0-7，值越小优先级越高
+	CPUAffinity          []int         `json:"cpu_affinity,omitempty" yaml:"cpu_affinity,omitempty"` // 允许运行的CPU列表，默认不限制，仅支持Linux
+	OOMScoreAdj          int           `json:"oom_score_adj" yaml:"oom_score_adj"`                   // OOM评分调整值，-1000到1000，默认是0，表示继承父进程，仅支持Linux
+
+	StdoutLogfile         string `json:"stdout_logfile" yaml:"stdout_logfile"`                     // 日志文件，不存在时 supervisord 会自动创建日志文件）
+	StdoutLogFileMaxBytes int    `json:"stdout_logfile_max_bytes" yaml:"stdout_logfile_max_bytes"` // stdout 日志文件大小，默认50MB
+	StdoutLogFileBackups  int    `json:"stdout_logfile_backups" yaml:"stdout_logfile_backups"`     // stdout 日志文件备份数，默认是10
+	RedirectStderr        bool   `json:"redirect_stderr" yaml:"redirect_stderr"`                   // 把stderr重定向到stdout，默认false
+	StderrLogfile         string `json:"stderr_logfile" yaml:"stderr_logfile"`                     // 日志文件，进程启动后的标准错误写入该文件
+	StderrLogFileMaxBytes int    `json:"stderr_logfile_max_bytes" yaml:"stderr_logfile_max_bytes"` // stderr 日志文件大小，默认50MB
+	StderrLogFileBackups  int    `json:"stderr_logfile_backups" yaml:"stderr_logfile_backups"`     // stderr 日志文件备份数，默认是10
+
+	StopAsGroup              bool             `json:"stop_as_group" yaml:"stop_as_group"`                             // 默认为false,进程被杀死时，是否向这个进程组发送stop信号，包括子进程
+	KillAsGroup              bool             `json:"kill_as_group" yaml:"kill_as_group"`                             // 默认为false，向进程组发送kill信号，包括子进程
+	StopAsTree               bool             `json:"stop_as_tree" yaml:"stop_as_tree"`                               // 默认为false，停止进程时向整个进程树(所有后代进程)发送信号，仅支持Linux
+	StopSignal               []string         `json:"stop_signal,omitempty" yaml:"stop_signal,omitempty"`             // 结束进程发送的信号
+	StopPlan                 *StopPlan        `json:"stop_plan,omitempty" yaml:"stop_plan,omitempty"`                 // 停止计划，设置后 StopSignal 和 StopWaitSecs 不再生效
+	StopWaitSecs             int              `json:"stop_wait_secs" yaml:"stop_wait_secs"`                           // 发送结束进程的信号后等待的秒数
+	StopWaitDuration         time.Duration    `json:"-" yaml:"-"`                                                     // 发送结束进程的信号后等待的时长，不为0时优先于 StopWaitSecs，用于小于1秒的精度
+	KillWaitSecs             int              `json:"kill_wait_secs" yaml:"kill_wait_secs"`                           // 强杀进程等待秒数
+	KillWaitDuration         time.Duration    `json:"-" yaml:"-"`                                                     // 强杀进程后等待的时长，不为0时优先于 KillWaitSecs，用于小于1秒的精度
+	Environment              *utils.StrStrMap `json:"environment" yaml:"environment"`                                 // 环境变量
+	RestartWhenBinaryChanged bool             `json:"restart_when_binary_changed" yaml:"restart_when_binary_changed"` // 当进程的二进制文件有修改，是否需要重启,默认false
+	ExtraFiles               []*os.File       `json:"-" yaml:"-"`                                                     // 继承主进程已经打开的文件列表，无法持久化
+	Extend                   *utils.AnyAnyMap `json:"extend" yaml:"extend"`                                           // 扩展参数
+	Isolation                *Isolation       `json:"isolation,omitempty" yaml:"isolation,omitempty"`                 // Linux 下的进程隔离配置，默认不隔离
+	Hooks                    Hooks            `json:"hooks" yaml:"hooks"`                                             // 生命周期钩子
+
+	StatsInterval     time.Duration `json:"stats_interval" yaml:"stats_interval"`           // 资源使用情况采样间隔，默认是0，表示不定时采样，只在调用Stats时采样
+	StatsWithChildren bool          `json:"stats_with_children" yaml:"stats_with_children"` // 资源统计是否包含所有子进程，默认false
+
+	MemoryLimit         uint64        `json:"memory_limit" yaml:"memory_limit"`                   // 常驻内存上限(字节)，默认是0，表示不限制
+	MemoryLimitDuration time.Duration `json:"memory_limit_duration" yaml:"memory_limit_duration"` // 常驻内存持续超出上限多久后重启进程
+	CPULimit            float64       `json:"cpu_limit" yaml:"cpu_limit"`                         // CPU使用率上限，100表示占满一个核，默认是0，表示不限制
+	CPULimitDuration    time.Duration `json:"cpu_limit_duration" yaml:"cpu_limit_duration"`       // CPU使用率持续超出上限多久后重启进程
 }
 
 // WithOption 定义选项函数类型
@@ -66,6 +94,18 @@ func WithCommand(opt string) WithOption {
 	}
 }
 
+// WithCommandLine 按照shell的规则解析命令行，设置启动命令、参数以及命令前的环境变量(例如 FOO=bar cmd)，并记录原始命令行
+//
+// 只包含命令和参数时直接启动命令；包含变量、通配符、重定向、管道等shell语法时通过shell执行，
+// 单个命令会加上 exec 前缀，shell被该命令替换，停止信号直接发送给该命令。Windows 下总是通过 cmd.exe 执行
+func WithCommandLine(cmd string) WithOption {
+	return func(options *Options) {
+		options.ensureMaps()
+		options.CommandLine = cmd
+		options.Command, options.Args = parseCommandLine(strings.TrimSpace(cmd), options.Environment)
+	}
+}
+
 // WithArgs 启动参数
 func WithArgs(opt ...string) WithOption {
 	return func(options *Options) {
@@ -87,12 +127,24 @@ func WithDirectory(opt string) WithOption {
 	}
 }
 
+// WithPidFile 设置进程的pid文件，进程进入运行状态时写入，退出后删除
+func WithPidFile(opt string) WithOption {
+	return func(options *Options) {
+		options.PidFile = opt
+	}
+}
+
 // WithStartSecs 指定启动多少秒后没有异常退出，则表示启动成功
 // // 未设置该值，则表示cmd.Start方法调用为出错，则表示启动成功，
 // // 设置了该值，则表示程序启动后需稳定运行指定的秒数后才算启动成功
 func WithStartSecs(opt int) WithOption {
+	return WithStartDuration(time.Duration(opt) * time.Second)
+}
+
+// WithStartDuration 指定启动后需要稳定运行多久才算启动成功，支持小于1秒的时长，例如 200*time.Millisecond
+func WithStartDuration(opt time.Duration) WithOption {
 	return func(options *Options) {
-		options.StartSecs = opt
+		setDuration(opt, &options.StartSecs, &options.StartDuration)
 	}
 }
 
@@ -119,8 +171,13 @@ func WithStartRetries(opt int) WithOption {
 
 // WithRestartPause 进程重启间隔秒数，默认是0，表示不间隔
 func WithRestartPause(opt int) WithOption {
+	return WithRestartPauseDuration(time.Duration(opt) * time.Second)
+}
+
+// WithRestartPauseDuration 进程重启间隔，支持小于1秒的时长
+func WithRestartPauseDuration(opt time.Duration) WithOption {
 	return func(options *Options) {
-		options.RestartPause = opt
+		setDuration(opt, &options.RestartPause, &options.RestartPauseDuration)
 	}
 }
 
@@ -131,6 +188,20 @@ func WithUser(opt string) WithOption {
 	}
 }
 
+// WithGroups 额外加入的用户组，可以是组名或gid
+func WithGroups(opt ...string) WithOption {
+	return func(options *Options) {
+		options.Groups = opt
+	}
+}
+
+// WithUmask 进程的umask，八进制字符串，例如"022"
+func WithUmask(opt string) WithOption {
+	return func(options *Options) {
+		options.Umask = opt
+	}
+}
+
 // WithPriority 进程启动优先级，默认999，值小的优先启动
 func WithPriority(opt int) WithOption {
 	return func(options *Options) {
@@ -152,6 +223,13 @@ func WithKillAsGroup(opt bool) WithOption {
 	}
 }
 
+// WithStopAsTree 默认为false，停止进程时向整个进程树(所有后代进程)发送信号，包括已经脱离进程组的子进程
+func WithStopAsTree(opt bool) WithOption {
+	return func(options *Options) {
+		options.StopAsTree = opt
+	}
+}
+
 // WithStopSignal 结束进程发送的信号列表
 func WithStopSignal(opt ...string) WithOption {
 	return func(options *Options) {
@@ -161,16 +239,64 @@ func WithStopSignal(opt ...string) WithOption {
 
 // WithStopWaitSecs 发送结束进程的信号后等待的秒数
 func WithStopWaitSecs(opt int) WithOption {
+	return WithStopWaitDuration(time.Duration(opt) * time.Second)
+}
+
+// WithStopWaitDuration 发送结束进程的信号后等待的时长，支持小于1秒的时长
+func WithStopWaitDuration(opt time.Duration) WithOption {
 	return func(options *Options) {
-		options.StopWaitSecs = opt
+		setDuration(opt, &options.StopWaitSecs, &options.StopWaitDuration)
 	}
 }
 
 // WithKillWaitSecs 强杀进程等待秒数
 func WithKillWaitSecs(opt int) WithOption {
+	return WithKillWaitDuration(time.Duration(opt) * time.Second)
+}
+
+// WithKillWaitDuration 强杀进程后等待的时长，支持小于1秒的时长
+func WithKillWaitDuration(opt time.Duration) WithOption {
 	return func(options *Options) {
-		options.KillWaitSecs = opt
+		setDuration(opt, &options.KillWaitSecs, &options.KillWaitDuration)
+	}
+}
+
+// 设置以秒为单位的配置项，整数秒只设置秒数，不是整数秒时同时设置优先生效的时长，
+// 保证相同的时长只有一种表示，重新加载配置时不会被识别为修改
+func setDuration(d time.Duration, secs *int, duration *time.Duration) {
+	*secs = int(d / time.Second)
+	*duration = 0
+	if d%time.Second != 0 {
+		*duration = d
+	}
+}
+
+// 以秒为单位的配置项实际生效的时长，时长不为0时优先使用时长
+func effectiveDuration(secs int, duration time.Duration) time.Duration {
+	if duration != 0 {
+		return duration
 	}
+	return time.Duration(secs) * time.Second
+}
+
+// 启动后需要稳定运行的时长
+func (that *Options) startDuration() time.Duration {
+	return effectiveDuration(that.StartSecs, that.StartDuration)
+}
+
+// 进程重启的间隔
+func (that *Options) restartPauseDuration() time.Duration {
+	return effectiveDuration(that.RestartPause, that.RestartPauseDuration)
+}
+
+// 发送结束进程的信号后等待的时长
+func (that *Options) stopWaitDuration() time.Duration {
+	return effectiveDuration(that.StopWaitSecs, that.StopWaitDuration)
+}
+
+// 强杀进程后等待的时长
+func (that *Options) killWaitDuration() time.Duration {
+	return effectiveDuration(that.KillWaitSecs, that.KillWaitDuration)
 }
 
 // WithSetEnvironment 环境变量
@@ -238,6 +364,85 @@ func WithRedirectStderr(opt bool) WithOption {
 	}
 }
 
+// WithStatsInterval 资源使用情况采样间隔
+// withChildren 为true时统计数据包含该进程的所有子进程
+func WithStatsInterval(interval time.Duration, withChildren ...bool) WithOption {
+	return func(options *Options) {
+		options.StatsInterval = interval
+		if len(withChildren) > 0 {
+			options.StatsWithChildren = withChildren[0]
+		}
+	}
+}
+
+// WithMemoryLimit 常驻内存持续超出上限指定时长后，平滑重启进程
+// 例如 WithMemoryLimit("2GB", time.Minute) 表示RSS持续1分钟超过2GB后重启
+func WithMemoryLimit(limit string, duration time.Duration) WithOption {
+	return func(options *Options) {
+		options.MemoryLimit = uint64(utils.GetBytes(limit, 0))
+		options.MemoryLimitDuration = duration
+	}
+}
+
+// WithCPULimit CPU使用率持续超出上限指定时长后，平滑重启进程
+func WithCPULimit(percent float64, duration time.Duration) WithOption {
+	return func(options *Options) {
+		options.CPULimit = percent
+		options.CPULimitDuration = duration
+	}
+}
+
+// 反序列化得到的配置中可能缺少环境变量和扩展参数，需要初始化后才能使用
+func (that *Options) ensureMaps() {
+	if that.Environment == nil {
+		that.Environment = utils.NewStrStrMap()
+	}
+	if that.Extend == nil {
+		that.Extend = utils.NewAnyAnyMap()
+	}
+}
+
+// Clone 深拷贝进程配置，切片、环境变量、扩展参数、停止计划、隔离配置和钩子都不与原配置共享，
+// 继承的文件仍然指向同一个 *os.File
+func (that Options) Clone() Options {
+	that.Args = slices.Clone(that.Args)
+	that.ExitCodes = slices.Clone(that.ExitCodes)
+	that.Groups = slices.Clone(that.Groups)
+	that.CPUAffinity = slices.Clone(that.CPUAffinity)
+	that.StopSignal = slices.Clone(that.StopSignal)
+	that.ExtraFiles = slices.Clone(that.ExtraFiles)
+	if that.Environment != nil {
+		environment := utils.NewStrStrMap()
+		environment.Sets(that.Environment.Map())
+		that.Environment = environment
+	}
+	if that.Extend != nil {
+		extend := utils.NewAnyAnyMap()
+		for key, val := range that.Extend.Map() {
+			extend.Set(key, val)
+		}
+		that.Extend = extend
+	}
+	if that.StopPlan != nil {
+		plan := *that.StopPlan
+		plan.Steps = slices.Clone(plan.Steps)
+		that.StopPlan = &plan
+	}
+	if that.Isolation != nil {
+		isolation := *that.Isolation
+		isolation.Namespaces = slices.Clone(isolation.Namespaces)
+		isolation.UidMappings = slices.Clone(isolation.UidMappings)
+		isolation.GidMappings = slices.Clone(isolation.GidMappings)
+		isolation.AmbientCaps = slices.Clone(isolation.AmbientCaps)
+		that.Isolation = &isolation
+	}
+	that.Hooks.PreStart = slices.Clone(that.Hooks.PreStart)
+	that.Hooks.PostStart = slices.Clone(that.Hooks.PostStart)
+	that.Hooks.PreStop = slices.Clone(that.Hooks.PreStop)
+	that.Hooks.PostStop = slices.Clone(that.Hooks.PostStop)
+	return that
+}
+
 // NewOptions 创建进程启动配置
 func NewOptions(opts ...WithOption) Options {
 	proc := Options{
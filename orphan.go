@@ -0,0 +1,194 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// pidRecord 进程启动记录，用于识别上一次运行遗留的进程
+type pidRecord struct {
+	Pid       int    `json:"pid"`        // 进程pid，同时也是它的进程组id
+	StartTime uint64 `json:"start_time"` // 进程启动时间，系统启动后的ticks
+	BootID    string `json:"boot_id"`    // 记录时系统的 boot_id，用于识别系统是否重启过
+}
+
+// EnableOrphanSweep 开启遗留进程清理
+// file: 记录进程pid的文件
+// 开启时会根据上一次运行记录的pid和启动时间，结束上一次运行遗留下来的进程树，
+// 只有记录的进程仍在运行且系统没有重启过时才会清理，避免误杀复用了pid的无关进程，
+// 之后每个进程启动时都会记录到该文件中，目前仅支持Linux
+// 同时开启持久化模式时，应该先调用 EnablePersistence，已经被接管的进程不会被清理
+func (m *Manager) EnableOrphanSweep(file string) error {
+	records, err := loadPidRecords(file)
+	if err != nil {
+		return err
+	}
+	for name, record := range records {
+		if m.isAdopted(name, record.Pid) {
+			continue
+		}
+		if killed := m.sweepTree(record); killed > 0 {
+			m.logger.Warnf("清理进程[%s]上一次运行遗留的%d个进程", name, killed)
+		}
+	}
+
+	m.pidRecordLock.Lock()
+	defer m.pidRecordLock.Unlock()
+	m.pidRecordFile = file
+	m.pidRecords = make(map[string]pidRecord)
+	return m.savePidRecords()
+}
+
+// 结束遗留的进程树，返回被结束的进程数量
+func (m *Manager) sweepTree(record pidRecord) int {
+	tree := findLeftoverTree(record)
+	if len(tree) == 0 {
+		return 0
+	}
+	for pid, startTime := range tree {
+		_ = signalPidChecked(pid, startTime, syscall.SIGTERM)
+	}
+	// 给遗留进程一点时间退出，超时后强制结束
+	// 进程组的首进程退出后无法再确认进程组的归属，所以只处理第一次找到的进程
+	for end := time.Now().Add(2 * time.Second); time.Now().Before(end) && treeAlive(tree); {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for pid, startTime := range tree {
+		_ = signalPidChecked(pid, startTime, syscall.SIGKILL)
+	}
+	return len(tree)
+}
+
+// 查找遗留的进程树，包括记录的进程本身、它的后代进程以及同一进程组内的进程
+// 系统重启过(boot_id 不同)或者记录的进程已经退出、启动时间不一致时，pid和进程组id都可能已经被其他进程复用，
+// 无法确认进程组的归属，返回空；启动时间早于记录的进程一定不属于该进程树
+// 返回进程pid与启动时间的对应关系
+func findLeftoverTree(record pidRecord) map[int]uint64 {
+	if record.BootID == "" || record.BootID != readBootID() {
+		return nil
+	}
+	leader, err := readProcStat(record.Pid)
+	if err != nil || leader.StartTime != record.StartTime || leader.State == 'Z' {
+		return nil
+	}
+	stats, err := listProcStats()
+	if err != nil {
+		return nil
+	}
+	tree := map[int]uint64{record.Pid: leader.StartTime}
+	for _, pid := range descendantPids(record.Pid) {
+		if child, err := readProcStat(pid); err == nil {
+			tree[pid] = child.StartTime
+		}
+	}
+	self := os.Getpid()
+	for _, st := range stats {
+		if st.PGrp == record.Pid && st.StartTime >= record.StartTime && st.Pid != self && st.State != 'Z' {
+			tree[st.Pid] = st.StartTime
+		}
+	}
+	return tree
+}
+
+// 进程树中是否还有进程没有退出
+func treeAlive(tree map[int]uint64) bool {
+	for pid, startTime := range tree {
+		if st, err := readProcStat(pid); err == nil && st.StartTime == startTime && st.State != 'Z' {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录进程的pid
+func (m *Manager) recordPid(name string, pid int) {
+	m.pidRecordLock.Lock()
+	defer m.pidRecordLock.Unlock()
+	if m.pidRecordFile == "" {
+		return
+	}
+	st, err := readProcStat(pid)
+	if err != nil {
+		return
+	}
+	m.pidRecords[name] = pidRecord{Pid: pid, StartTime: st.StartTime, BootID: readBootID()}
+	if err = m.savePidRecords(); err != nil {
+		m.logger.Warnf("记录进程[%s]的pid失败: %v", name, err)
+	}
+}
+
+// 进程退出后删除pid记录
+func (m *Manager) forgetPid(name string, pid int) {
+	m.pidRecordLock.Lock()
+	defer m.pidRecordLock.Unlock()
+	if m.pidRecordFile == "" {
+		return
+	}
+	if record, ok := m.pidRecords[name]; !ok || record.Pid != pid {
+		return
+	}
+	delete(m.pidRecords, name)
+	if err := m.savePidRecords(); err != nil {
+		m.logger.Warnf("删除进程[%s]的pid记录失败: %v", name, err)
+	}
+}
+
+// 保存pid记录，先写临时文件再重命名，避免写入一半时崩溃导致文件损坏
+func (m *Manager) savePidRecords() error {
+	data, err := json.Marshal(m.pidRecords)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.pidRecordFile, data, 0o644)
+}
+
+// 读取pid记录，文件不存在时返回空记录
+func loadPidRecords(file string) (map[string]pidRecord, error) {
+	records := make(map[string]pidRecord)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return records, nil
+	}
+	if err = json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// writeFileAtomic 原子写入文件
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
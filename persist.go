@@ -0,0 +1,234 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// persistRecord 持久化模式下每个运行中进程的记录
+type persistRecord struct {
+	Name      string  `json:"name"`       // 进程名
+	Pid       int     `json:"pid"`        // 进程pid
+	StartTime uint64  `json:"start_time"` // 进程启动时间，系统启动后的ticks，用于识别pid复用
+	Options   Options `json:"options"`    // 进程配置
+}
+
+// EnablePersistence 开启持久化模式
+// dir: 保存进程记录的目录
+// 开启后子进程不再随着管理器退出而被强制结束，每个进程启动后都会把 pid、启动时间和配置记录到该目录中。
+// 管理器重启后再次开启时，会接管仍在运行的进程并继续监控它们，接管的进程会使用记录中的配置注册到管理器中，
+// 所以应该在创建进程之前调用，已经注册了的同名进程会直接使用已注册的配置。
+// 开启后子进程的输出直接写入日志文件而不经过管理器的管道，日志不再按大小切割。目前仅支持Linux
+func (m *Manager) EnablePersistence(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建持久化目录失败: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	m.persistLock.Lock()
+	m.persistDir = dir
+	m.persistLock.Unlock()
+
+	for _, file := range files {
+		record, err := loadPersistRecord(file)
+		if err != nil {
+			m.logger.Warnf("读取进程记录[%s]失败: %v", file, err)
+			continue
+		}
+		st, err := readProcStat(record.Pid)
+		if err != nil || st.StartTime != record.StartTime || st.State == 'Z' {
+			m.logger.Infof("进程[%s]在管理器重启期间已经退出", record.Name)
+			_ = os.Remove(file)
+			continue
+		}
+
+		proc := m.Find(record.Name)
+		if proc == nil {
+			proc = NewProcessByOptions(record.Options)
+			proc.Manager = m
+			m.processes.Store(record.Name, proc)
+		}
+		if err = proc.adopt(record.Pid, record.StartTime); err != nil {
+			m.logger.Warnf("接管进程[%s]失败: %v", record.Name, err)
+			continue
+		}
+		m.logger.Infof("接管仍在运行的进程[%s], pid: %d", record.Name, record.Pid)
+	}
+	return nil
+}
+
+// 是否开启了持久化模式
+func (m *Manager) persistent() bool {
+	m.persistLock.Lock()
+	defer m.persistLock.Unlock()
+	return m.persistDir != ""
+}
+
+// 记录运行中的进程
+func (m *Manager) savePersistRecord(proc *Process, pid int) {
+	m.persistLock.Lock()
+	dir := m.persistDir
+	m.persistLock.Unlock()
+	if dir == "" {
+		return
+	}
+	st, err := readProcStat(pid)
+	if err != nil {
+		m.logger.Warnf("记录进程[%s]失败: %v", proc.GetName(), err)
+		return
+	}
+	data, err := json.Marshal(&persistRecord{
+		Name:      proc.GetName(),
+		Pid:       pid,
+		StartTime: st.StartTime,
+		Options:   proc.option,
+	})
+	if err == nil {
+		err = writeFileAtomic(persistRecordFile(dir, proc.GetName()), data, 0o600)
+	}
+	if err != nil {
+		m.logger.Warnf("记录进程[%s]失败: %v", proc.GetName(), err)
+	}
+}
+
+// 进程退出后删除记录
+func (m *Manager) removePersistRecord(name string, pid int) {
+	m.persistLock.Lock()
+	dir := m.persistDir
+	m.persistLock.Unlock()
+	if dir == "" {
+		return
+	}
+	file := persistRecordFile(dir, name)
+	if record, err := loadPersistRecord(file); err == nil && record.Pid == pid {
+		_ = os.Remove(file)
+	}
+}
+
+// 进程记录文件的路径，进程名中可能包含路径分隔符，需要转义
+func persistRecordFile(dir, name string) string {
+	return filepath.Join(dir, url.PathEscape(name)+".json")
+}
+
+// 读取进程记录
+func loadPersistRecord(file string) (*persistRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	record := &persistRecord{}
+	if err = json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	record.Options.ensureMaps()
+	return record, nil
+}
+
+// 接管一个仍在运行、但不是由当前管理器启动的进程
+func (that *Process) adopt(pid int, startTime uint64) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	that.lock.Lock()
+	if that.inStart {
+		that.lock.Unlock()
+		return fmt.Errorf("进程[%s]已经启动", that.GetName())
+	}
+	// 打开pidfd后再次核对启动时间，确认接管的是记录中的那个进程
+	pidfd := openPidfd(pid)
+	if st, err := readProcStat(pid); err != nil || st.StartTime != startTime {
+		that.lock.Unlock()
+		if pidfd != nil {
+			_ = pidfd.Close()
+		}
+		return fmt.Errorf("进程[%s]已经退出", that.GetName())
+	}
+	that.setPidfd(pidfd)
+	that.runPid = pid
+	that.cmd = &exec.Cmd{
+		Path:    that.option.Command,
+		Args:    append([]string{that.option.Command}, that.option.Args...),
+		Process: proc,
+	}
+	that.inStart = true
+	that.stopByUser = false
+	that.startTime = procStartTime(startTime)
+	that.changeStateTo(Running)
+	that.lock.Unlock()
+
+	that.startStatsSampler(pid)
+	go that.watchAdopted(pid, startTime)
+	return nil
+}
+
+// 监控接管的进程，它不是当前进程的子进程，无法通过 wait 获取退出状态，
+// 支持pidfd时等待pidfd可读，否则只能轮询它是否还存在
+func (that *Process) watchAdopted(pid int, startTime uint64) {
+	that.lock.RLock()
+	pidfd := that.pidfd
+	that.lock.RUnlock()
+	for {
+		if pidfd != nil {
+			if pidfdWait(pidfd, time.Second) {
+				break
+			}
+			continue
+		}
+		st, err := readProcStat(pid)
+		if err != nil || st.StartTime != startTime || st.State == 'Z' {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	that.Manager.logger.Infof("接管的程序[%s]已经运行结束", that.GetName())
+	that.stopStatsSampler()
+	that.Manager.removePersistRecord(that.GetName(), pid)
+
+	that.lock.Lock()
+	that.stopTime = time.Now()
+	if that.state == Stopping {
+		that.changeStateTo(Stopped)
+	} else {
+		that.changeStateTo(Exited)
+	}
+	that.removePidFile(pid)
+	// 进程可能成为了僵尸进程，清空进程对象，避免被误判为仍在运行
+	that.cmd.Process = nil
+	that.setPidfd(nil)
+	that.runPid = 0
+	that.inStart = false
+	stopByUser := that.stopByUser
+	that.lock.Unlock()
+
+	// 执行退出后的钩子，接管的进程无法获取退出码
+	if err := that.runHooks(HookPostStop, HookEvent{Pid: pid, ExitCode: -1}); err != nil {
+		that.Manager.logger.Warnf("进程[%s]的%v", that.GetName(), err)
+	}
+
+	// 接管的进程无法获取退出码，按照非预期退出处理
+	if !stopByUser && that.option.AutoReStart != AutoReStartFalse {
+		that.Manager.logger.Infof("因为该进程设置了自动重启, 自动重启进程[%s],", that.GetName())
+		that.Start(false)
+	}
+}
+
+// 持久化模式下不设置 Pdeathsig，子进程在管理器退出后继续运行
+func (that *Process) keepAfterManagerExit() bool {
+	return that.Manager != nil && that.Manager.persistent()
+}
+
+// 判断pid记录是否对应一个已被接管的进程
+func (m *Manager) isAdopted(name string, pid int) bool {
+	proc := m.Find(name)
+	return proc != nil && proc.runningPid() == pid
+}
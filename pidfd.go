@@ -0,0 +1,13 @@
+package process
+
+import (
+	"os"
+)
+
+// 替换进程的pidfd，并关闭原来的pidfd，调用方需要持有锁
+func (that *Process) setPidfd(pidfd *os.File) {
+	if that.pidfd != nil {
+		_ = that.pidfd.Close()
+	}
+	that.pidfd = pidfd
+}
@@ -0,0 +1,80 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPidfd 打开进程的pidfd，内核不支持(低于5.3)时返回nil，调用方需要回退到基于pid的操作
+// pidfd 始终指向打开时的那个进程，即使该进程退出后pid被复用，也不会误操作其他进程
+func openPidfd(pid int) *os.File {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil
+	}
+	return os.NewFile(uintptr(fd), "pidfd")
+}
+
+// pidfdSignal 通过pidfd向进程发送信号，进程已经退出时返回 syscall.ESRCH
+func pidfdSignal(pidfd *os.File, sig os.Signal) error {
+	localSig, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("不支持的信号类型")
+	}
+	return unix.PidfdSendSignal(int(pidfd.Fd()), localSig, nil, 0)
+}
+
+// pidfdAlive 判断pidfd指向的进程是否还在运行
+func pidfdAlive(pidfd *os.File) bool {
+	return pidfdSignal(pidfd, syscall.Signal(0)) == nil
+}
+
+// pidfdWait 等待pidfd指向的进程退出，进程退出后pidfd变为可读，超时返回false
+func pidfdWait(pidfd *os.File, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(pidfd.Fd()), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		return err == nil && n > 0
+	}
+}
+
+// signalPidChecked 向指定启动时间的进程发送信号
+// 先打开pidfd再核对启动时间，核对通过后pidfd不会再指向其他进程，避免pid复用导致误杀
+func signalPidChecked(pid int, startTime uint64, sig os.Signal) error {
+	pidfd := openPidfd(pid)
+	if pidfd != nil {
+		defer pidfd.Close()
+	}
+	st, err := readProcStat(pid)
+	if err != nil || st.StartTime != startTime {
+		return syscall.ESRCH
+	}
+	if pidfd != nil {
+		return pidfdSignal(pidfd, sig)
+	}
+	return syscall.Kill(pid, sig.(syscall.Signal))
+}
+
+// openChildPidfd 打开刚启动的子进程的pidfd
+// 子进程可能在打开前就已经退出并被其他地方回收，pid也可能已被复用，所以需要确认它仍然是当前进程的子进程
+func openChildPidfd(pid int) *os.File {
+	pidfd := openPidfd(pid)
+	if pidfd == nil {
+		return nil
+	}
+	if st, err := readProcStat(pid); err != nil || st.PPid != os.Getpid() {
+		_ = pidfd.Close()
+		return nil
+	}
+	return pidfd
+}
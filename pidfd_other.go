@@ -0,0 +1,42 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/darkit/process/signals"
+)
+
+// openPidfd 非Linux系统不支持pidfd
+func openPidfd(_ int) *os.File {
+	return nil
+}
+
+// pidfdSignal 非Linux系统不支持pidfd
+func pidfdSignal(_ *os.File, _ os.Signal) error {
+	return errors.New("当前系统不支持pidfd")
+}
+
+// pidfdAlive 非Linux系统不支持pidfd
+func pidfdAlive(_ *os.File) bool {
+	return false
+}
+
+// pidfdWait 非Linux系统不支持pidfd
+func pidfdWait(_ *os.File, _ time.Duration) bool {
+	return false
+}
+
+// signalPidChecked 非Linux系统无法核对进程的启动时间，直接发送信号
+func signalPidChecked(pid int, _ uint64, sig os.Signal) error {
+	return signals.KillPid(pid, sig)
+}
+
+// openChildPidfd 非Linux系统不支持pidfd
+func openChildPidfd(_ int) *os.File {
+	return nil
+}
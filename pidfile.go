@@ -0,0 +1,104 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/darkit/process/signals"
+)
+
+// 写入进程的pid文件，未设置pid文件时不写入
+func (that *Process) writePidFile() {
+	file := that.option.PidFile
+	if file == "" || that.cmd == nil || that.cmd.Process == nil {
+		return
+	}
+	data := []byte(strconv.Itoa(that.cmd.Process.Pid) + "\n")
+	if err := writeFileAtomic(file, data, 0o644); err != nil {
+		that.Manager.logger.Warnf("写入进程[%s]的pid文件[%s]失败: %v", that.option.Name, file, err)
+	}
+}
+
+// 删除进程的pid文件，文件中的pid不是当前进程时(例如已被新的进程覆盖)不删除
+func (that *Process) removePidFile(pid int) {
+	file := that.option.PidFile
+	if file == "" {
+		return
+	}
+	if filePid, err := readPidFile(file); err != nil || filePid != pid {
+		return
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		that.Manager.logger.Warnf("删除进程[%s]的pid文件[%s]失败: %v", that.option.Name, file, err)
+	}
+}
+
+// 清理遗留的pid文件，文件中记录的进程已经不存在时删除该文件
+func (that *Process) cleanStalePidFile() {
+	file := that.option.PidFile
+	if file == "" {
+		return
+	}
+	pid, err := readPidFile(file)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil && signals.CheckPidExist(pid) {
+		that.Manager.logger.Warnf("进程[%s]的pid文件[%s]中记录的进程[%d]仍在运行", that.option.Name, file, pid)
+		return
+	}
+	that.Manager.logger.Infof("清理进程[%s]遗留的pid文件[%s]", that.option.Name, file)
+	_ = os.Remove(file)
+}
+
+// LockPidFile 创建管理器的pid文件，并通过文件锁保证同时只有一个实例在运行
+// 文件已存在但没有被锁定时，说明是上一次运行遗留的pid文件，会被覆盖
+func (m *Manager) LockPidFile(file string) error {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		if pid, e := readPidFile(file); e == nil {
+			return fmt.Errorf("已有实例正在运行(pid %d)", pid)
+		}
+		return fmt.Errorf("锁定pid文件[%s]失败: %w", file, err)
+	}
+	if pid, e := readPidFile(file); e == nil && pid != os.Getpid() {
+		m.logger.Infof("清理遗留的pid文件[%s], 进程[%d]已经退出", file, pid)
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	m.pidFile = f
+	return nil
+}
+
+// ReleasePidFile 删除管理器的pid文件并释放文件锁
+func (m *Manager) ReleasePidFile() error {
+	if m.pidFile == nil {
+		return nil
+	}
+	err := removeLockedFile(m.pidFile)
+	m.pidFile = nil
+	return err
+}
+
+// 读取pid文件中的pid
+func readPidFile(file string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
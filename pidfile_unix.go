@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// 对文件加排他锁，文件已被其他进程锁定时立即返回错误
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// 删除并关闭已锁定的文件，先删除再关闭释放锁，避免其他实例锁定即将被删除的文件
+func removeLockedFile(f *os.File) error {
+	err := os.Remove(f.Name())
+	_ = f.Close()
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package process
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// 对文件加排他锁，文件已被其他进程锁定时立即返回错误
+// 锁定的是文件内容之外的区域，不影响其他进程读取pid
+func lockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: 1}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+}
+
+// 关闭并删除已锁定的文件，Windows下打开中的文件无法删除，需要先关闭释放锁再删除
+func removeLockedFile(f *os.File) error {
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -13,7 +14,6 @@ import (
 	"time"
 
 	"github.com/darkit/process/proclog"
-	"github.com/darkit/process/signals"
 	"github.com/darkit/process/utils"
 )
 
@@ -35,12 +35,27 @@ type Process struct {
 	stdoutLog     proclog.Logger
 	stderrLog     proclog.Logger
 	monitorCancel context.CancelFunc
+
+	statsLock   sync.Mutex         // 资源采样锁
+	lastStats   *statsSample       // 最近一次的资源采样
+	statsCancel context.CancelFunc // 停止资源定时采样
+
+	memoryOverSince time.Time // 内存开始超出限制的时间
+	cpuOverSince    time.Time // CPU开始超出限制的时间
+	limitTriggered  bool      // 已经因为资源超限触发了重启
+	restartReason   string    // 最近一次自动重启的原因
+
+	pidfd             *os.File      // 进程的pidfd，内核不支持时为nil
+	runPid            int           // 正在运行的进程pid，在 lock 保护下于启动后设置、退出后清零
+	runUser           *runUser      // 进程切换后的运行用户，为nil表示未切换
+	spawnErr          string        // 最近一次启动失败的原因
+	startThreadExited chan struct{} // 关闭后，启动进程时锁定的线程退出
+	directLogs        []*os.File    // 持久化模式下直接交给子进程的日志文件，启动后关闭
 }
 
 // NewProcess 创建进程对象
 func NewProcess(opts ...WithOption) *Process {
 	options := NewOptions()
-	options.Environment.Sets(utils.Map())
 	dir, _ := os.Getwd()
 	options.Directory = dir
 	for _, opt := range opts {
@@ -66,12 +81,11 @@ func NewProcessByOptions(options Options) *Process {
 	return proc
 }
 
-// NewProcessCmd 按命令启动
+// NewProcessCmd 按命令行启动，命令行的解析规则见 WithCommandLine，命令前的环境变量优先于 environment
 func NewProcessCmd(cmd string, environment map[string]string) *Process {
 	return NewProcess(
-		WithCommand(getShell()),
-		WithArgs(append([]string{getShellOption()}, parseCommand(cmd)...)...),
 		WithEnvironment(environment),
+		WithCommandLine(cmd),
 	)
 }
 
@@ -148,64 +162,33 @@ func (that *Process) Stop(wait bool) {
 	}
 	that.Manager.logger.Infof("正在停止程序[%s]", that.GetName())
 
-	// 获取程序的正常退出信号
-	sigs := that.option.StopSignal
-	// 发送信号后的等待秒数
-	waitSecond := time.Duration(that.option.StopWaitSecs) * time.Second
-	// 发送强制结束信号后的等待秒数
-	killWaitSecond := time.Duration(that.option.KillWaitSecs) * time.Second
-	// 是否同时停止进程组
-	stopAsGroup := that.option.StopAsGroup
-	// 是否强制杀死进程组
-	killAsGroup := that.option.KillAsGroup
-	if stopAsGroup && !killAsGroup {
-		that.Manager.logger.Errorf("不能够同时设置 stopAsGroup=true 和 killAsGroup=false")
-	}
-	var stopped int32 = 0
-
-	// 添加 context 控制
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(that.option.StopWaitSecs)*time.Second)
-	defer cancel()
+	// 执行停止前的钩子，钩子要求中止时进程继续运行
+	if err := that.runHooks(HookPreStop, HookEvent{Pid: that.runningPid()}); err != nil {
+		that.Manager.logger.Warnf("进程[%s]的%v, 中止停止", that.GetName(), err)
+		that.lock.Lock()
+		that.stopByUser = false
+		that.lock.Unlock()
+		return
+	}
+
+	// 获取停止计划，整个停止过程的超时时间为执行完所有步骤需要的时间，再留出一秒的余量
+	plan := that.stopPlan()
+	ctx, cancel := context.WithTimeout(context.Background(), plan.duration()+time.Second)
+
+	that.lock.Lock()
+	if that.state == Starting || that.state == Running {
+		that.changeStateTo(Stopping)
+	}
+	that.lock.Unlock()
 
 	stopChan := make(chan struct{})
 	go func() {
 		defer close(stopChan)
-		for i := 0; i < len(sigs) && atomic.LoadInt32(&stopped) == 0; i++ {
-			// 获取需要发送的信号
-			sig := signals.ToSignal(sigs[i])
-			that.Manager.logger.Infof("发送结束进程信号[%s]给进程[%s]", that.GetName(), sigs[i])
-			// 发送结束进程信号给程序
-			_ = that.Signal(sig, stopAsGroup)
-			endTime := time.Now().Add(waitSecond)
-			// 等待指定的时候后，判断当前进程是否还在存
-			for endTime.After(time.Now()) {
-				// 如果进程不存在了
-				if that.checkState() {
-					atomic.StoreInt32(&stopped, 1)
-					break
-				}
-				time.Sleep(10 * time.Millisecond)
-			}
-		}
-		// 如果发送了设置的信号后，进程还未停止，则需要强制结束该进程
-		if atomic.LoadInt32(&stopped) == 0 {
-			that.Manager.logger.Infof("强制结束程序[%s]", that.GetName())
-			_ = that.Signal(syscall.SIGKILL, killAsGroup)
-			killEndTime := time.Now().Add(killWaitSecond)
-			for killEndTime.After(time.Now()) {
-				// 如果进程结束成功
-				if that.checkState() {
-					atomic.StoreInt32(&stopped, 1)
-					break
-				}
-				time.Sleep(10 * time.Millisecond)
-			}
-			// 无论如何，发送了强杀信号后，默认认为它强杀成功
-			atomic.StoreInt32(&stopped, 1)
-		}
+		that.executeStopPlan(plan)
 	}()
 
 	_exit := func() {
+		defer cancel()
 		select {
 		case <-ctx.Done():
 			that.Manager.logger.Warnf("停止进程[%s]超时", that.GetName())
@@ -235,10 +218,10 @@ func (that *Process) run(finishCb func()) {
 
 	that.startTime = time.Now()
 	atomic.StoreInt32(that.retryTimes, 0)
-	// 指定启动多少秒后没有异常退出，则表示启动成功
-	startSecs := that.option.StartSecs
-	// 进程重启间隔秒数，默认是0，表示不间隔
-	restartPause := that.option.RestartPause
+	// 启动后稳定运行多久没有异常退出，则表示启动成功
+	startDuration := that.option.startDuration()
+	// 进程重启间隔，默认是0，表示不间隔
+	restartPause := that.option.restartPauseDuration()
 
 	var once sync.Once
 	finishCbWrapper := func() {
@@ -249,27 +232,61 @@ func (that *Process) run(finishCb func()) {
 	for !that.stopByUser {
 		// 如果进程启动失败，需要重试，则需要判断配置，重试启动是否需要间隔制定时间
 		if restartPause > 0 && atomic.LoadInt32(that.retryTimes) != 0 {
-			that.lock.Lock()
-			that.Manager.logger.Infof("不能立刻重启程序[%s],需要等待%d秒", that.option.Name, restartPause)
-			time.Sleep(time.Duration(restartPause) * time.Second)
+			that.Manager.logger.Infof("不能立刻重启程序[%s],需要等待%v", that.option.Name, restartPause)
+			// 等待期间释放锁，避免阻塞状态查询和停止进程
 			that.lock.Unlock()
+			time.Sleep(restartPause)
+			that.lock.Lock()
+			if that.stopByUser {
+				that.stopBeforeSpawn(finishCbWrapper)
+				break
+			}
 		}
 		// 程序指定结束时间，如果在该时间内未退出，则表示进程启动成功
-		endTime := time.Now().Add(time.Duration(startSecs) * time.Second)
+		endTime := time.Now().Add(startDuration)
 		// 更新进程状态
 		that.changeStateTo(Starting)
 		// 启动次数+1
 		atomic.AddInt32(that.retryTimes, 1)
+		// 清理上一次运行遗留的pid文件
+		that.cleanStalePidFile()
+		// 执行启动前的钩子，执行期间释放锁，避免阻塞状态查询
+		that.lock.Unlock()
+		err := that.runHooks(HookPreStart, HookEvent{})
+		that.lock.Lock()
+		if that.stopByUser {
+			that.stopBeforeSpawn(finishCbWrapper)
+			break
+		}
+		if err != nil {
+			that.spawnErr = err.Error()
+			if atomic.LoadInt32(that.retryTimes) >= int32(that.option.StartRetries) {
+				that.Manager.logger.Errorf("程序[%s]重启次数已经达到最大限限额 %v", that.option.Name, err)
+				that.failToStartProgram(finishCbWrapper)
+				break
+			}
+			that.Manager.logger.Errorf("程序[%s]启动失败稍后将再次重试! 错误信息: %v", that.option.Name, err)
+			that.changeStateTo(Backoff)
+			continue
+		}
 		// 创建启动命令行
-		err := that.createProgramCommand()
+		err = that.createProgramCommand()
 		if err != nil {
 			that.Manager.logger.Errorf("程序[%s]不能创建进程 %v", that.option.Name, err)
+			that.spawnErr = err.Error()
 			that.failToStartProgram(finishCbWrapper)
 			break
 		}
 		// 启动程序
-		err = that.cmd.Start()
+		release := that.Manager.lockSpawn()
+		err = that.startCommand()
+		if err == nil {
+			that.Manager.trackChild(that.cmd.Process.Pid)
+		}
+		release()
+		that.closeDirectLogs()
 		if err != nil {
+			that.spawnErr = err.Error()
 			// 重试次数已经大于设置中的最大重试次数
 			if atomic.LoadInt32(that.retryTimes) >= int32(that.option.StartRetries) {
 				that.Manager.logger.Errorf("程序[%s]重启次数已经达到最大限限额 %v", that.option.Name, err)
@@ -282,6 +299,10 @@ func (that *Process) run(finishCb func()) {
 				continue
 			}
 		}
+		that.spawnErr = ""
+		// 打开进程的pidfd，后续的存活检查和信号发送都通过它进行，避免pid复用
+		that.setPidfd(openChildPidfd(that.cmd.Process.Pid))
+		that.runPid = that.cmd.Process.Pid
 		// 设置标准输出日志的pid
 		if that.stdoutLog != nil {
 			that.stdoutLog.SetPid(that.Pid())
@@ -290,17 +311,31 @@ func (that *Process) run(finishCb func()) {
 		if that.stderrLog != nil {
 			that.stderrLog.SetPid(that.Pid())
 		}
-		monitorExited := int32(0)
-		programExited := int32(0)
+		// 设置进程的OOM评分调整值，其他调度参数在启动进程的线程上设置，每次重启都会重新设置
+		if err = that.applySchedule(that.cmd.Process.Pid); err != nil {
+			that.Manager.logger.Warnf("设置程序[%s]的调度参数失败: %v", that.option.Name, err)
+		}
+		// 开启资源定时采样
+		that.startStatsSampler(that.cmd.Process.Pid)
+		// 记录进程pid，用于下次启动时清理遗留的进程树
+		that.Manager.recordPid(that.option.Name, that.cmd.Process.Pid)
+		// 持久化模式下记录进程，管理器重启后可以重新接管
+		that.Manager.savePersistRecord(that, that.cmd.Process.Pid)
+		// 程序退出后关闭 programExited，监控协程结束后关闭 monitorExited
+		programExited := make(chan struct{})
+		monitorExited := make(chan struct{})
 		// 如果未设置启动监视时长，则表示cmd.start成功就算该程序启动成功
-		if startSecs <= 0 {
+		if startDuration <= 0 {
+			close(monitorExited)
 			that.Manager.logger.Infof("程序[%s]启动成功", that.option.Name)
 			that.changeStateTo(Running)
+			go that.runPostStartHooks(that.cmd.Process.Pid)
 			go finishCbWrapper()
 		} else {
-			// 如果设置了启动监视时长，则表示需要程序启动了，稳定运行指定秒数后才算启动成功
+			// 如果设置了启动监视时长，则表示需要程序启动了，稳定运行指定时长后才算启动成功
 			go func() {
-				that.monitorProgramIsRunning(endTime, &monitorExited, &programExited)
+				that.monitorProgramIsRunning(endTime, programExited)
+				close(monitorExited)
 				finishCbWrapper()
 			}()
 		}
@@ -310,23 +345,29 @@ func (that *Process) run(finishCb func()) {
 			that.Manager.logger.Debugf("进程正在运行[%s]等待退出", that.option.Name)
 		}
 		that.lock.Unlock()
-		that.waitForExit(int64(startSecs))
-		// 修改程序退出码
-		atomic.StoreInt32(&programExited, 1)
-		// 等待监控协程退出
-		for atomic.LoadInt32(&monitorExited) == 0 {
-			time.Sleep(time.Duration(10) * time.Millisecond)
-		}
+		that.waitForExit()
+		// 通知监控协程程序已经退出，并等待监控协程退出
+		close(programExited)
+		<-monitorExited
 		that.lock.Lock()
 
+		// 进程在停止过程中退出
+		if that.state == Stopping {
+			that.changeStateTo(Stopped)
+			that.Manager.logger.Infof("程序[%s]已经停止", that.option.Name)
+			that.runPostStopHooks(that.cmd.ProcessState)
+			break
+		}
 		// 如果程序的运行状态为 Running，则更改它的状态
 		if that.state == Running {
 			that.changeStateTo(Exited)
 			that.Manager.logger.Infof("程序[%s]已经结束", that.option.Name)
+			that.runPostStopHooks(that.cmd.ProcessState)
 			break
 		} else {
 			that.changeStateTo(Backoff)
 		}
+		that.runPostStopHooks(that.cmd.ProcessState)
 		// 如果重试次数已经超过了设置的最大重试次数
 		if atomic.LoadInt32(that.retryTimes) >= int32(that.option.StartRetries) {
 			that.Manager.logger.Errorf("不能启动程序[%s],因为已经超出了它的最大重试值: %d", that.option.Name, that.option.StartRetries)
@@ -334,6 +375,16 @@ func (that *Process) run(finishCb func()) {
 			break
 		}
 	}
+	// 启动过程中被用户停止时也需要通知等待启动的调用方
+	finishCbWrapper()
+}
+
+// 进程创建之前被用户停止，没有进程需要等待退出，直接进入停止状态并通知等待启动的调用方
+func (that *Process) stopBeforeSpawn(finishCb func()) {
+	that.stopTime = time.Now()
+	that.changeStateTo(Stopped)
+	that.Manager.logger.Infof("程序[%s]在启动前被停止", that.option.Name)
+	finishCb()
 }
 
 // 创建程序的cmd对象
@@ -344,12 +395,20 @@ func (that *Process) createProgramCommand() (err error) {
 		return err
 	}
 	// 设置程序运行时用户
-	if that.setUser() != nil {
-		return fmt.Errorf("设置程序运行时用户[%s]失败", that.option.User)
+	if err = that.setUser(); err != nil {
+		return fmt.Errorf("设置程序运行时用户[%s]失败: %w", that.option.User, err)
+	}
+	// 设置程序的隔离配置
+	if err = that.setIsolation(that.cmd.SysProcAttr); err != nil {
+		return fmt.Errorf("设置程序隔离配置失败: %w", err)
 	}
 
-	// 设置程序重启变化监控
-	if err = that.setProgramRestartChangeMonitor(that.cmd.Args[0]); err != nil {
+	// 设置程序重启变化监控，监控查找 PATH 之后的程序文件，相对路径相对于运行目录
+	programPath := that.cmd.Path
+	if !filepath.IsAbs(programPath) && that.option.Directory != "" {
+		programPath = filepath.Join(that.option.Directory, programPath)
+	}
+	if err = that.setProgramRestartChangeMonitor(programPath); err != nil {
 		that.Manager.logger.Errorf("设置程序重启监控失败: %v", err)
 	}
 
@@ -364,7 +423,15 @@ func (that *Process) createProgramCommand() (err error) {
 	// 设置程序的dir
 	that.setDir()
 	// 设置程序的运行日志存放未知
-	that.setLog()
+	if that.keepAfterManagerExit() {
+		if err := that.setDirectLog(); err != nil {
+			return fmt.Errorf("打开日志文件失败: %w", err)
+		}
+	} else {
+		that.setLog()
+	}
+	// 日志文件归属于程序的运行用户
+	that.chownLogFiles()
 	// 程序的标准输入
 	that.stdin, _ = that.cmd.StdinPipe()
 
@@ -373,6 +440,9 @@ func (that *Process) createProgramCommand() (err error) {
 
 // 判断进程是否在运行
 func (that *Process) isRunning() bool {
+	if that.pidfd != nil {
+		return pidfdAlive(that.pidfd)
+	}
 	if that.cmd != nil && that.cmd.Process != nil {
 		if runtime.GOOS == "windows" {
 			proc, err := os.FindProcess(that.cmd.Process.Pid)
@@ -388,12 +458,14 @@ func (that *Process) IsAutoStart() bool {
 	return that.option.AutoStart
 }
 
-// 设置进程运行的环境变量
+// 设置进程运行的环境变量，在继承的进程管理器环境变量之后依次追加切换用户的变量和 Environment 中明确设置的变量，
+// 同名变量以最后出现的为准
 func (that *Process) setEnv() {
-	if that.option.Environment.Size() > 0 {
-		_ = utils.SetMap(that.option.Environment.Map())
+	env := append(utils.All(), that.userEnv()...)
+	for key, val := range that.option.Environment.Map() {
+		env = append(env, key+"="+val)
 	}
-	that.cmd.Env = utils.All()
+	that.cmd.Env = env
 }
 
 // 设置进程的运行目录
@@ -419,23 +491,34 @@ func (that *Process) setLog() {
 // 设置程序启动失败状态
 func (that *Process) failToStartProgram(finishCb func()) {
 	that.changeStateTo(Fatal)
+	that.Manager.emit(Event{Type: EventFatal, Process: that.option.Name, Message: that.spawnErr})
+	// 回滚需要停止和重启进程，不能在启动流程中执行
+	go that.Manager.autoRollback(that.option.Name)
 	finishCb()
 }
 
 // 监控进程是否正在运行中
-func (that *Process) monitorProgramIsRunning(endTime time.Time, monitorExited *int32, programExited *int32) {
-	// 未到超时时间
-	for time.Now().Before(endTime) && atomic.LoadInt32(programExited) == 0 {
-		time.Sleep(time.Duration(100) * time.Millisecond)
+func (that *Process) monitorProgramIsRunning(endTime time.Time, programExited <-chan struct{}) {
+	timer := time.NewTimer(time.Until(endTime))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-programExited:
+		return
 	}
-	atomic.StoreInt32(monitorExited, 1)
 
 	that.lock.Lock()
 	defer that.lock.Unlock()
 	// 进程在此期间未退出
-	if atomic.LoadInt32(programExited) == 0 && that.state == Starting {
+	select {
+	case <-programExited:
+		return
+	default:
+	}
+	if that.state == Starting {
 		that.Manager.logger.Infof("进程[%s]启动成功", that.option.Name)
 		that.changeStateTo(Running)
+		go that.runPostStartHooks(that.cmd.Process.Pid)
 	}
 }
 
@@ -461,16 +544,34 @@ func (that *Process) isAutoRestart() bool {
 }
 
 // 阻塞等待进程运行结束
-func (that *Process) waitForExit(_ int64) {
+func (that *Process) waitForExit() {
 	_ = that.cmd.Wait()
+	that.Manager.untrackChild(that.cmd.Process.Pid)
+	that.removePidFile(that.cmd.Process.Pid)
+	that.stopStatsSampler()
+	if that.startThreadExited != nil {
+		close(that.startThreadExited)
+		that.startThreadExited = nil
+	}
+	that.Manager.forgetPid(that.option.Name, that.cmd.Process.Pid)
+	that.Manager.removePersistRecord(that.option.Name, that.cmd.Process.Pid)
 	if that.cmd.ProcessState != nil {
 		that.Manager.logger.Infof("程序[%s]已经运行结束, 退出码为:%v", that.option.Name, that.cmd.ProcessState)
+		that.Manager.emit(Event{
+			Type:     EventExited,
+			Process:  that.option.Name,
+			Pid:      that.cmd.Process.Pid,
+			Message:  that.cmd.ProcessState.String(),
+			ExitCode: exitCodeOf(that.cmd.ProcessState),
+		})
 	} else {
 		that.Manager.logger.Infof("程序[%s]已经运行结束", that.option.Name)
 	}
 	that.lock.Lock()
 	defer that.lock.Unlock()
 	that.stopTime = time.Now()
+	that.setPidfd(nil)
+	that.runPid = 0
 	if that.stdoutLog != nil {
 		_ = that.stdoutLog.Close()
 	}
@@ -503,6 +604,9 @@ func (that *Process) Clone() (*Process, error) {
 // 更改进程的运行状态
 func (that *Process) changeStateTo(procState State) {
 	that.state = procState
+	if procState == Running {
+		that.writePidFile()
+	}
 }
 
 // 监控程序重启变化
@@ -4,5 +4,8 @@ import "syscall"
 
 func (that *Process) sysProcAttrSetPGid(s *syscall.SysProcAttr) {
 	s.Setpgid = true
-	s.Pdeathsig = syscall.SIGKILL
+	// 持久化模式下子进程需要在管理器退出后继续运行
+	if !that.keepAfterManagerExit() {
+		s.Pdeathsig = syscall.SIGKILL
+	}
 }
@@ -0,0 +1,191 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks /proc 中CPU时间的单位(USER_HZ)，Linux 对用户态固定导出为100
+const clockTicks = 100
+
+// procStat /proc/<pid>/stat 中关心的字段
+type procStat struct {
+	Pid       int    // 进程pid
+	Comm      string // 进程名
+	State     byte   // 进程状态，R/S/D/Z/T等
+	PPid      int    // 父进程pid
+	PGrp      int    // 进程组id
+	UTime     uint64 // 用户态CPU时间(ticks)
+	STime     uint64 // 内核态CPU时间(ticks)
+	Threads   int    // 线程数
+	StartTime uint64 // 启动时间，系统启动后的ticks
+	VSize     uint64 // 虚拟内存大小(字节)
+	RSS       int64  // 常驻内存页数
+}
+
+// readProcStat 读取并解析 /proc/<pid>/stat
+func readProcStat(pid int) (*procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcStat(data)
+}
+
+// parseProcStat 解析stat内容，进程名中可能包含空格和括号，所以以最后一个')'为分界
+func parseProcStat(data []byte) (*procStat, error) {
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("无法解析的stat内容: %q", data)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data[:start])))
+	if err != nil {
+		return nil, err
+	}
+	// fields[0] 对应stat中的第3个字段(state)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("stat字段数量不足: %d", len(fields))
+	}
+	st := &procStat{
+		Pid:   pid,
+		Comm:  string(data[start+1 : end]),
+		State: fields[0][0],
+	}
+	st.PPid, _ = strconv.Atoi(fields[1])
+	st.PGrp, _ = strconv.Atoi(fields[2])
+	st.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	st.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	st.Threads, _ = strconv.Atoi(fields[17])
+	st.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	st.VSize, _ = strconv.ParseUint(fields[20], 10, 64)
+	st.RSS, _ = strconv.ParseInt(fields[21], 10, 64)
+	return st, nil
+}
+
+// listProcStats 读取系统中所有进程的stat信息，读取失败的进程(例如已经退出)会被忽略
+func listProcStats() ([]*procStat, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]*procStat, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		st, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// descendantPids 通过 PPid 关系找出指定进程的所有后代进程(不包含自身)，按层级顺序返回
+func descendantPids(pid int) []int {
+	stats, err := listProcStats()
+	if err != nil {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, st := range stats {
+		children[st.PPid] = append(children[st.PPid], st.Pid)
+	}
+	var result []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parent] {
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+	return result
+}
+
+// procStartTime 把stat中的启动时间(系统启动后的ticks)转换为时间
+func procStartTime(ticks uint64) time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Now()
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			btime, err := strconv.ParseInt(strings.TrimSpace(line[6:]), 10, 64)
+			if err != nil {
+				break
+			}
+			return time.Unix(btime, 0).Add(time.Duration(ticks) * time.Second / clockTicks)
+		}
+	}
+	return time.Now()
+}
+
+// readBootID 读取本次系统启动的唯一标识，系统重启后会变化
+func readBootID() string {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readProcStatus 读取 /proc/<pid>/status 中的键值对
+func readProcStatus(pid int) (map[string]string, error) {
+	return readProcKeyValues(fmt.Sprintf("/proc/%d/status", pid))
+}
+
+// readProcIO 读取 /proc/<pid>/io 中的键值对，读取其他用户的进程需要权限
+func readProcIO(pid int) (map[string]string, error) {
+	return readProcKeyValues(fmt.Sprintf("/proc/%d/io", pid))
+}
+
+// countProcFDs 统计进程打开的文件句柄数量
+func countProcFDs(pid int) (int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// readProcKeyValues 解析 "Key: value" 格式的proc文件
+func readProcKeyValues(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		pos := strings.IndexByte(line, ':')
+		if pos < 0 {
+			continue
+		}
+		values[strings.TrimSpace(line[:pos])] = strings.TrimSpace(line[pos+1:])
+	}
+	return values, scanner.Err()
+}
+
+// parseKB 解析 "1234 kB" 格式的值，返回字节数
+func parseKB(val string) uint64 {
+	val = strings.TrimSpace(strings.TrimSuffix(val, "kB"))
+	n, _ := strconv.ParseUint(val, 10, 64)
+	return n * 1024
+}
@@ -0,0 +1,45 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+	"time"
+)
+
+// procStat 非Linux系统没有 /proc，仅用于保持接口一致
+type procStat struct {
+	Pid       int
+	PPid      int
+	PGrp      int
+	State     byte
+	StartTime uint64
+}
+
+var errNoProcfs = errors.New("当前系统不支持读取/proc")
+
+// readProcStat 非Linux系统不支持
+func readProcStat(_ int) (*procStat, error) {
+	return nil, errNoProcfs
+}
+
+// listProcStats 非Linux系统不支持
+func listProcStats() ([]*procStat, error) {
+	return nil, errNoProcfs
+}
+
+// descendantPids 非Linux系统不支持，总是返回空
+func descendantPids(_ int) []int {
+	return nil
+}
+
+// readBootID 非Linux系统不支持，返回空
+func readBootID() string {
+	return ""
+}
+
+// procStartTime 非Linux系统不支持，返回当前时间
+func procStartTime(_ uint64) time.Time {
+	return time.Now()
+}
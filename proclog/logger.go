@@ -51,7 +51,13 @@ func splitLogFile(logFile string) []string {
 
 // 创建日志对象
 func createLogger(programName string, logFile string, locker sync.Locker, maxBytes int64, backups int, props map[string]string) Logger {
-	if logFile == "/dev/stdout" || logFile == "/dev/stderr" || logFile == "/dev/null" {
+	if logFile == "/dev/stdout" {
+		return NewStdoutLogger()
+	}
+	if logFile == "/dev/stderr" {
+		return NewStderrLogger()
+	}
+	if logFile == "/dev/null" {
 		return NewNullLogger()
 	}
 	if logFile == "syslog" {
@@ -28,6 +28,17 @@ func (that *MultiLogger) RemoveLogger(logger Logger) {
 	}
 }
 
+// Reset 替换所有的日志对象并关闭原有的日志对象，用于在进程运行时切换日志文件
+func (that *MultiLogger) Reset(loggers ...Logger) {
+	that.lock.Lock()
+	old := that.loggers
+	that.loggers = loggers
+	that.lock.Unlock()
+	for _, logger := range old {
+		_ = logger.Close()
+	}
+}
+
 func (that *MultiLogger) Write(p []byte) (n int, err error) {
 	that.lock.Lock()
 	defer that.lock.Unlock()
@@ -0,0 +1,198 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 每个进程最多保留的配置版本数，超出时删除最早的版本
+const revisionLimit = 20
+
+// AutoRollbackAuthor 自动回滚产生的配置版本的修改人
+const AutoRollbackAuthor = "auto-rollback"
+
+// Revision 进程配置的一个版本
+type Revision struct {
+	Revision int       `json:"revision"`           // 版本号，同一个进程名从1开始递增
+	Author   string    `json:"author"`             // 修改人，通过 WithAuthor 设置，直接创建进程时为空
+	Time     time.Time `json:"time"`               // 修改时间
+	Diff     []string  `json:"diff,omitempty"`     // 与上一个版本相比修改的配置项，第一个版本为空
+	Rollback int       `json:"rollback,omitempty"` // 回滚产生的版本对应的原版本号
+	Options  Options   `json:"options"`            // 该版本的配置
+}
+
+// Revisions 获取进程的配置版本，按版本号升序排列，进程被移除后仍然保留
+func (m *Manager) Revisions(name string) []Revision {
+	m.revisionLock.Lock()
+	defer m.revisionLock.Unlock()
+	revisions := make([]Revision, len(m.revisions[name]))
+	for i, revision := range m.revisions[name] {
+		revision.Diff = append([]string(nil), revision.Diff...)
+		revision.Options = revision.Options.Clone()
+		revisions[i] = revision
+	}
+	return revisions
+}
+
+// EnableAutoRollback 开启自动回滚，进程的配置修改后 window 时间内进入 Fatal 状态时回滚到上一个版本，window 为0时关闭
+//
+// 回滚产生的版本不会再次自动回滚，避免两个版本都无法启动时反复回滚
+func (m *Manager) EnableAutoRollback(window time.Duration) {
+	m.revisionLock.Lock()
+	defer m.revisionLock.Unlock()
+	m.rollbackWindow = window
+}
+
+// Rollback 把进程的配置恢复为指定版本，并记录为一个新的版本
+//
+// 与 UpdateProcess 一样按修改的配置项决定是否重启进程，此外处于 Fatal 状态的进程会按恢复的配置重新启动，
+// 已经被移除的进程会被重新创建，设置了自动启动时启动进程
+func (m *Manager) Rollback(name string, revision int, opts ...ApplyOption) (ApplyChange, error) {
+	target, ok := m.findRevision(name, revision)
+	if !ok {
+		return ApplyChange{Name: name}, fmt.Errorf("进程[%s]没有版本[%d]", name, revision)
+	}
+	config := applyConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	m.logger.Infof("回滚进程[%s]的配置到版本[%d]", name, revision)
+	m.applyLock.Lock()
+	defer m.applyLock.Unlock()
+	return m.applyProcess(target.Options, config, revision)
+}
+
+// UpdateProcess 修改已存在的进程的配置，并记录为一个新的版本
+//
+// 修改了启动相关配置的运行中进程会按新的配置重启，其他情况直接修改配置，没有修改时返回的 Action 为空
+func (m *Manager) UpdateProcess(options Options, opts ...ApplyOption) (ApplyChange, error) {
+	if m.Find(options.Name) == nil {
+		return ApplyChange{Name: options.Name}, fmt.Errorf("没有找到进程[%s]", options.Name)
+	}
+	config := applyConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	m.applyLock.Lock()
+	defer m.applyLock.Unlock()
+	return m.applyProcess(options, config, 0)
+}
+
+// 把单个进程调整为指定的配置，进程不存在时创建，rollback 为回滚的原版本号
+func (m *Manager) applyProcess(options Options, config applyConfig, rollback int) (ApplyChange, error) {
+	change := ApplyChange{Name: options.Name}
+	options.ensureMaps()
+	if err := options.Validate(); err != nil {
+		return change, err
+	}
+
+	proc := m.Find(options.Name)
+	if proc == nil {
+		change.Action = ApplyAdd
+		if config.dryRun {
+			return change, nil
+		}
+		proc, err := m.newProcessByOptions(options, config.author, rollback)
+		if err != nil {
+			change.Error = err.Error()
+			return change, err
+		}
+		if proc.option.AutoStart {
+			proc.Start(false)
+		}
+		return change, nil
+	}
+
+	change, options = planUpdate(proc, options)
+	fatal := rollback > 0 && proc.GetState() == Fatal
+	if config.dryRun || (len(change.Fields) == 0 && !fatal) {
+		if len(change.Fields) == 0 {
+			change.Action = ""
+		}
+		return change, nil
+	}
+	if len(change.Fields) > 0 {
+		m.executeChange(&change, options)
+		if change.Error != "" {
+			return change, errors.New(change.Error)
+		}
+		m.recordRevision(options, config.author, change.Fields, rollback)
+	}
+	if fatal && change.Action != ApplyRestart {
+		// 启动失败的进程不会自动重启，回滚后按恢复的配置重新启动
+		proc.Start(false)
+	}
+	return change, nil
+}
+
+// 查找进程的指定版本
+func (m *Manager) findRevision(name string, revision int) (Revision, bool) {
+	m.revisionLock.Lock()
+	defer m.revisionLock.Unlock()
+	for _, r := range m.revisions[name] {
+		if r.Revision == revision {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+// 记录进程配置的新版本，diff 为空时与上一个版本比较
+func (m *Manager) recordRevision(options Options, author string, diff []string, rollback int) {
+	m.revisionLock.Lock()
+	defer m.revisionLock.Unlock()
+	if m.revisions == nil {
+		m.revisions = make(map[string][]Revision)
+	}
+	history := m.revisions[options.Name]
+	revision := Revision{Revision: 1, Author: author, Time: time.Now(), Diff: diff, Rollback: rollback, Options: options.Clone()}
+	if n := len(history); n > 0 {
+		revision.Revision = history[n-1].Revision + 1
+		if diff == nil {
+			revision.Diff, _ = diffOptions(history[n-1].Options, options)
+		}
+	}
+	history = append(history, revision)
+	if len(history) > revisionLimit {
+		history = append([]Revision(nil), history[len(history)-revisionLimit:]...)
+	}
+	m.revisions[options.Name] = history
+}
+
+// 进程进入 Fatal 状态时，配置在自动回滚的时间窗口内修改过的进程回滚到上一个版本
+func (m *Manager) autoRollback(name string) {
+	m.revisionLock.Lock()
+	history := m.revisions[name]
+	if m.rollbackWindow <= 0 || len(history) < 2 || m.rollingBack[name] {
+		m.revisionLock.Unlock()
+		return
+	}
+	latest, previous := history[len(history)-1], history[len(history)-2]
+	if latest.Rollback > 0 || time.Since(latest.Time) > m.rollbackWindow {
+		m.revisionLock.Unlock()
+		return
+	}
+	// 自动重启的进程会反复进入 Fatal 状态，同一时间只执行一次回滚
+	if m.rollingBack == nil {
+		m.rollingBack = make(map[string]bool)
+	}
+	m.rollingBack[name] = true
+	m.revisionLock.Unlock()
+	defer func() {
+		m.revisionLock.Lock()
+		delete(m.rollingBack, name)
+		m.revisionLock.Unlock()
+	}()
+
+	m.logger.Warnf("进程[%s]的配置版本[%d]启动失败, 自动回滚到版本[%d]", name, latest.Revision, previous.Revision)
+	if _, err := m.Rollback(name, previous.Revision, WithAuthor(AutoRollbackAuthor)); err != nil {
+		m.logger.Errorf("进程[%s]自动回滚失败: %v", name, err)
+		return
+	}
+	m.emit(Event{
+		Type:    EventRollback,
+		Process: name,
+		Message: fmt.Sprintf("配置版本[%d]启动失败, 已回滚到版本[%d]", latest.Revision, previous.Revision),
+	})
+}
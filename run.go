@@ -0,0 +1,221 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownTimeout 收到退出信号后停止所有进程的默认超时时间
+const defaultShutdownTimeout = 30 * time.Second
+
+// runConfig Manager.Run 的运行参数
+type runConfig struct {
+	shutdownTimeout time.Duration
+	reload          func() error
+}
+
+// RunOption Manager.Run 的选项函数
+type RunOption func(*runConfig)
+
+// WithShutdownTimeout 设置收到退出信号后停止所有进程的超时时间，超时后强制结束剩余的进程，默认30秒
+func WithShutdownTimeout(timeout time.Duration) RunOption {
+	return func(config *runConfig) {
+		config.shutdownTimeout = timeout
+	}
+}
+
+// WithReloadHandler 设置收到 SIGHUP 信号时的重新加载函数，未设置时忽略 SIGHUP
+func WithReloadHandler(reload func() error) RunOption {
+	return func(config *runConfig) {
+		config.reload = reload
+	}
+}
+
+// ShutdownResult 进程的停止结果
+type ShutdownResult struct {
+	Name     string        `json:"name"`     // 进程名
+	State    State         `json:"state"`    // 停止后的状态
+	Killed   bool          `json:"killed"`   // 是否因为超过截止时间被强制结束
+	Duration time.Duration `json:"duration"` // 停止耗时
+}
+
+// Run 按优先级启动所有自动启动的进程，并阻塞运行到 ctx 结束或收到 SIGTERM/SIGINT 信号，
+// 然后在超时时间内停止所有进程，收到 SIGHUP 信号时调用重新加载函数。启动过程中退出时不再启动剩余的进程
+func (m *Manager) Run(ctx context.Context, opts ...RunOption) error {
+	config := runConfig{shutdownTimeout: defaultShutdownTimeout}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	// 退出时先取消启动，等待正在启动的一组进程完成后再停止所有进程，避免停止后又有进程被启动
+	startCtx, cancelStart := context.WithCancel(ctx)
+	defer cancelStart()
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		m.startAutoStartProcesses(startCtx)
+	}()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			m.logger.Infof("停止所有进程")
+			break loop
+		case sig := <-sigs:
+			if sig != syscall.SIGHUP {
+				m.logger.Infof("收到信号[%s], 停止所有进程", sig)
+				break loop
+			}
+			if config.reload == nil {
+				continue
+			}
+			m.logger.Infof("收到信号[%s], 重新加载配置", sig)
+			if err := config.reload(); err != nil {
+				m.logger.Errorf("重新加载配置失败: %v", err)
+			}
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+	defer cancel()
+	cancelStart()
+	select {
+	case <-started:
+	case <-shutdownCtx.Done():
+	}
+	_, err := m.Shutdown(shutdownCtx)
+	return err
+}
+
+// Shutdown 按优先级从低到高(Priority值大的先停止)停止所有进程，优先级相同的进程同时停止，
+// 到达 ctx 的截止时间后强制结束剩余的进程，返回每个进程的停止结果
+func (m *Manager) Shutdown(ctx context.Context) ([]ShutdownResult, error) {
+	groups := m.priorityGroups()
+	results := make([]ShutdownResult, 0)
+	var errs []error
+	for i := len(groups) - 1; i >= 0; i-- {
+		groupResults := make([]ShutdownResult, len(groups[i]))
+		var wg sync.WaitGroup
+		for j, proc := range groups[i] {
+			wg.Add(1)
+			go func(j int, proc *Process) {
+				defer wg.Done()
+				groupResults[j] = proc.shutdown(ctx)
+			}(j, proc)
+		}
+		wg.Wait()
+		for _, result := range groupResults {
+			if result.Killed {
+				m.logger.Warnf("进程[%s]停止超时，已强制结束", result.Name)
+			}
+			if result.State == Stopping {
+				errs = append(errs, fmt.Errorf("进程[%s]未能停止", result.Name))
+			}
+		}
+		results = append(results, groupResults...)
+	}
+	if ctx.Err() != nil {
+		errs = append([]error{fmt.Errorf("停止进程超时: %w", ctx.Err())}, errs...)
+	}
+	return results, errors.Join(errs...)
+}
+
+// 按优先级从高到低(Priority值小的在前)启动所有自动启动的进程，优先级相同的进程同时启动，
+// ctx 结束后不再启动后面的分组
+func (m *Manager) startAutoStartProcesses(ctx context.Context) {
+	for _, group := range m.priorityGroups() {
+		if ctx.Err() != nil {
+			m.logger.Infof("停止启动剩余的自动启动进程")
+			return
+		}
+		var wg sync.WaitGroup
+		for _, proc := range group {
+			if !proc.IsAutoStart() || proc.isInStart() {
+				continue
+			}
+			wg.Add(1)
+			go func(proc *Process) {
+				defer wg.Done()
+				proc.Start(true)
+			}(proc)
+		}
+		wg.Wait()
+	}
+}
+
+// 把所有进程按照优先级分组，按Priority从小到大排序
+func (m *Manager) priorityGroups() [][]*Process {
+	var procs []*Process
+	m.ForEachProcess(func(p *Process) {
+		procs = append(procs, p)
+	})
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].option.Priority != procs[j].option.Priority {
+			return procs[i].option.Priority < procs[j].option.Priority
+		}
+		return procs[i].option.Name < procs[j].option.Name
+	})
+	var groups [][]*Process
+	for i, proc := range procs {
+		if i == 0 || proc.option.Priority != procs[i-1].option.Priority {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], proc)
+	}
+	return groups
+}
+
+// 在截止时间前按照停止计划停止进程，超时后强制结束进程
+func (that *Process) shutdown(ctx context.Context) ShutdownResult {
+	result := ShutdownResult{Name: that.GetName()}
+	start := time.Now()
+	if ctx.Err() == nil {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			that.Stop(true)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+	if !that.checkState() {
+		result.Killed = true
+		that.kill()
+		that.waitStopped(that.option.killWaitDuration())
+	}
+	result.State = that.GetState()
+	result.Duration = time.Since(start)
+	return result
+}
+
+// 立即强制结束进程，不执行停止计划
+func (that *Process) kill() {
+	that.lock.Lock()
+	that.stopByUser = true
+	isRunning := that.isRunning()
+	if isRunning && (that.state == Starting || that.state == Running) {
+		that.changeStateTo(Stopping)
+	}
+	that.lock.Unlock()
+	if !isRunning {
+		return
+	}
+	_ = that.Signal(syscall.SIGKILL, that.option.KillAsGroup)
+	if that.option.StopAsTree {
+		that.signalTree(that.collectTree(nil), syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,49 @@
+package process
+
+// IO调度类型
+const (
+	IOClassNone       = ""            // 不设置，继承父进程
+	IOClassRealtime   = "realtime"    // 实时
+	IOClassBestEffort = "best-effort" // 尽力而为，系统默认
+	IOClassIdle       = "idle"        // 空闲时才进行IO
+)
+
+// Schedule 进程实际生效的调度参数
+type Schedule struct {
+	Nice        int    `json:"nice"`          // nice值
+	IOClass     string `json:"io_class"`      // IO调度类型
+	IOPriority  int    `json:"io_priority"`   // IO优先级，0-7，值越小优先级越高
+	CPUAffinity []int  `json:"cpu_affinity"`  // 允许运行的CPU列表
+	OOMScoreAdj int    `json:"oom_score_adj"` // OOM评分调整值
+}
+
+// WithNice 进程的nice值，-20到19，值越小优先级越高，默认是0，表示继承父进程
+func WithNice(opt int) WithOption {
+	return func(options *Options) {
+		options.Nice = opt
+	}
+}
+
+// WithIOPriority 进程的IO调度类型和优先级
+// class: 可选值：[realtime,best-effort,idle]
+// priority: 0-7，值越小优先级越高，idle 类型会忽略该值
+func WithIOPriority(class string, priority int) WithOption {
+	return func(options *Options) {
+		options.IOClass = class
+		options.IOPriority = priority
+	}
+}
+
+// WithCPUAffinity 进程允许运行的CPU列表
+func WithCPUAffinity(cpus ...int) WithOption {
+	return func(options *Options) {
+		options.CPUAffinity = cpus
+	}
+}
+
+// WithOOMScoreAdj 进程的OOM评分调整值，-1000到1000，值越大越容易在内存不足时被杀死
+func WithOOMScoreAdj(opt int) WithOption {
+	return func(options *Options) {
+		options.OOMScoreAdj = opt
+	}
+}
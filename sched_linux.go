@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioprioWhoProcess = 1  // IOPRIO_WHO_PROCESS
+	ioprioClassShift = 13 // IOPRIO_CLASS_SHIFT
+	cpuSetWords      = 16 // CPU掩码的长度，最多支持1024个CPU
+)
+
+// IO调度类型与内核中编号的对应关系
+var ioClasses = map[string]int{
+	IOClassRealtime:   1,
+	IOClassBestEffort: 2,
+	IOClassIdle:       3,
+}
+
+// 是否需要在启动进程的线程上设置调度参数
+func (that *Process) hasThreadSchedule() bool {
+	return that.option.Nice != 0 || that.option.IOClass != IOClassNone || len(that.option.CPUAffinity) > 0
+}
+
+// 在当前线程上设置nice值、IO优先级和CPU亲和性，它们都是线程属性，在该线程上 fork 出的子进程会继承，
+// 所以子进程从 exec 开始就使用这些调度参数，调用方需要锁定当前线程并且不再解锁
+func (that *Process) setThreadSchedule() error {
+	opt := that.option
+	var errs []error
+	if opt.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, opt.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("设置nice值失败: %w", err))
+		}
+	}
+	if opt.IOClass != IOClassNone {
+		class, ok := ioClasses[opt.IOClass]
+		if !ok {
+			errs = append(errs, fmt.Errorf("不支持的IO调度类型[%s]", opt.IOClass))
+		} else {
+			prio := uintptr(class<<ioprioClassShift | opt.IOPriority&0x7)
+			if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, prio); errno != 0 {
+				errs = append(errs, fmt.Errorf("设置IO优先级失败: %w", errno))
+			}
+		}
+	}
+	if len(opt.CPUAffinity) > 0 {
+		var mask [cpuSetWords]uint64
+		for _, cpu := range opt.CPUAffinity {
+			if cpu < 0 || cpu >= cpuSetWords*64 {
+				errs = append(errs, fmt.Errorf("无效的CPU编号[%d]", cpu))
+				continue
+			}
+			mask[cpu/64] |= 1 << (uint(cpu) % 64)
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask))); errno != 0 {
+			errs = append(errs, fmt.Errorf("设置CPU亲和性失败: %w", errno))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 在进程启动后设置OOM评分调整值，它是整个进程的属性，不能在启动进程的线程上设置，
+// 所以从 exec 到设置完成之间的短暂时间内进程仍然使用继承的值
+func (that *Process) applySchedule(pid int) error {
+	if that.option.OOMScoreAdj == 0 {
+		return nil
+	}
+	file := fmt.Sprintf("/proc/%d/oom_score_adj", pid)
+	if err := os.WriteFile(file, []byte(strconv.Itoa(that.option.OOMScoreAdj)), 0o644); err != nil {
+		return fmt.Errorf("设置OOM评分调整值失败: %w", err)
+	}
+	return nil
+}
+
+// 读取进程实际生效的调度参数
+func readSchedule(pid int) *Schedule {
+	sched := &Schedule{}
+	// getpriority 系统调用返回的是 20-nice
+	if prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid); err == nil {
+		sched.Nice = 20 - prio
+	}
+	if r, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0); errno == 0 {
+		class := int(r) >> ioprioClassShift
+		sched.IOPriority = int(r) & 0x7
+		for name, val := range ioClasses {
+			if val == class {
+				sched.IOClass = name
+			}
+		}
+		// 未设置IO调度类型时，内核按照nice值计算尽力而为类型的优先级
+		if class == 0 {
+			sched.IOClass = IOClassBestEffort
+			sched.IOPriority = (sched.Nice + 20) / 5
+		}
+	}
+	var mask [cpuSetWords]uint64
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, uintptr(pid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask))); errno == 0 {
+		for i, word := range mask {
+			for bit := 0; bit < 64; bit++ {
+				if word&(1<<uint(bit)) != 0 {
+					sched.CPUAffinity = append(sched.CPUAffinity, i*64+bit)
+				}
+			}
+		}
+	}
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid)); err == nil {
+		sched.OOMScoreAdj, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	return sched
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+// 设置进程的OOM评分调整值，非Linux系统不支持，直接忽略
+func (that *Process) applySchedule(_ int) error {
+	return nil
+}
+
+// 读取进程实际生效的调度参数，非Linux系统不支持
+func readSchedule(_ int) *Schedule {
+	return nil
+}
@@ -4,6 +4,8 @@
 package process
 
 import (
+	"fmt"
+	"os"
 	"os/user"
 	"strconv"
 	"strings"
@@ -12,8 +14,17 @@ import (
 
 // 设置进程的运行用户
 func (that *Process) setUser() error {
+	that.runUser = nil
 	userName := that.option.User
 	if len(userName) == 0 {
+		// 未切换用户时，只追加额外的用户组
+		if len(that.option.Groups) > 0 {
+			groups, err := lookupGroupIds(that.option.Groups)
+			if err != nil {
+				return err
+			}
+			that.cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid()), Groups: groups}
+		}
 		return nil
 	}
 
@@ -46,6 +57,61 @@ func (that *Process) setUser() error {
 			return err
 		}
 	}
-	that.cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: true}
+
+	// 与 initgroups 一样，加入该用户所属的所有附属组，再追加额外指定的用户组
+	groupIds, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("获取用户[%s]的附属组失败: %w", userName, err)
+	}
+	groups, err := lookupGroupIds(append(groupIds, that.option.Groups...))
+	if err != nil {
+		return err
+	}
+	that.cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	that.runUser = &runUser{
+		Uid:  int(uid),
+		Gid:  int(gid),
+		Name: u.Username,
+		Home: u.HomeDir,
+	}
 	return nil
 }
+
+// 把用户组名或gid转换为gid列表，并去除重复值
+func lookupGroupIds(names []string) ([]uint32, error) {
+	groups := make([]uint32, 0, len(names))
+	seen := make(map[uint32]bool)
+	for _, name := range names {
+		gid, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return nil, err
+			}
+			if gid, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+				return nil, err
+			}
+		}
+		if !seen[uint32(gid)] {
+			seen[uint32(gid)] = true
+			groups = append(groups, uint32(gid))
+		}
+	}
+	return groups, nil
+}
+
+// 把日志文件的所有者修改为进程的运行用户，这样进程切换用户后依然可以管理自己的日志
+func (that *Process) chownLogFiles() {
+	if that.runUser == nil {
+		return
+	}
+	files := append(splitLogFiles(that.GetStdoutLogfile()), splitLogFiles(that.GetStderrLogfile())...)
+	for _, file := range files {
+		if !isRegularLogFile(file) {
+			continue
+		}
+		if err := os.Chown(file, that.runUser.Uid, that.runUser.Gid); err != nil && !os.IsNotExist(err) {
+			that.Manager.logger.Warnf("修改日志文件[%s]的所有者失败: %v", file, err)
+		}
+	}
+}
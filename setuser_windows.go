@@ -6,3 +6,7 @@ package process
 func (that *Process) setUser() error {
 	return nil
 }
+
+// 修改日志文件的所有者，当前系统不支持切换运行用户
+func (that *Process) chownLogFiles() {
+}
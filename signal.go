@@ -39,6 +39,10 @@ func (that *Process) sendSignals(sigs []string, sigChildren bool) {
 func (that *Process) sendSignal(sig os.Signal, sigChildren bool) error {
 	if that.cmd != nil && that.cmd.Process != nil {
 		that.Manager.logger.Infof("发送信号[%s]到进程[%s]", sig, that.GetName())
+		// 只向进程自身发送信号时优先使用pidfd，进程已经退出时不会误发给复用了该pid的其他进程
+		if that.pidfd != nil && !sigChildren {
+			return pidfdSignal(that.pidfd, sig)
+		}
 		err := signals.Kill(that.cmd.Process, sig, sigChildren)
 		return err
 	}
@@ -6,6 +6,7 @@ package signals
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -46,15 +47,31 @@ var signalMap = map[string]os.Signal{
 	"SIGXFSZ":   syscall.SIGXFSZ,
 }
 
-// ToSignal 传入信号字符串，返回标准信号
-func ToSignal(signalName string) os.Signal {
-	if !strings.HasPrefix(signalName, "SIG") {
-		signalName = fmt.Sprintf("SIG%s", signalName)
+// ParseSignal 解析信号名称，支持 TERM、SIGTERM 等不区分大小写的名称以及信号编号，无法识别时返回错误
+func ParseSignal(signalName string) (os.Signal, error) {
+	name := strings.ToUpper(strings.TrimSpace(signalName))
+	if n, err := strconv.Atoi(name); err == nil {
+		if n <= 0 || n > 64 {
+			return nil, fmt.Errorf("无效的信号编号[%d]", n)
+		}
+		return syscall.Signal(n), nil
+	}
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalMap[name]; ok {
+		return sig, nil
 	}
-	if sig, ok := signalMap[signalName]; ok {
-		return sig
+	return nil, fmt.Errorf("无法识别的信号[%s]", signalName)
+}
+
+// ToSignal 传入信号字符串，返回标准信号，无法识别的信号返回 SIGTERM
+func ToSignal(signalName string) os.Signal {
+	sig, err := ParseSignal(signalName)
+	if err != nil {
+		return syscall.SIGTERM
 	}
-	return syscall.SIGTERM
+	return sig
 }
 
 // Kill 向指定的进程发送信号
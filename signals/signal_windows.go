@@ -6,26 +6,40 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 )
 
-// ToSignal 传入信号字符串，返回标准信号
+// signalMap windows 下可识别的信号，USR1、USR2 与 TERM 一样会结束进程
+var signalMap = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGTERM,
+	"SIGUSR2": syscall.SIGTERM,
+}
+
+// ParseSignal 解析信号名称，支持 TERM、SIGTERM 等不区分大小写的名称，无法识别时返回错误
+func ParseSignal(signalName string) (os.Signal, error) {
+	name := strings.ToUpper(strings.TrimSpace(signalName))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalMap[name]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("无法识别的信号[%s]", signalName)
+}
+
+// ToSignal 传入信号字符串，返回标准信号，无法识别的信号返回 SIGTERM
 func ToSignal(signalName string) os.Signal {
-	if signalName == "HUP" {
-		return syscall.SIGHUP
-	} else if signalName == "INT" {
-		return syscall.SIGINT
-	} else if signalName == "QUIT" {
-		return syscall.SIGQUIT
-	} else if signalName == "KILL" {
-		return syscall.SIGKILL
-	} else if signalName == "USR1" {
-		return syscall.SIGTERM
-	} else if signalName == "USR2" {
-		return syscall.SIGTERM
-	} else {
+	sig, err := ParseSignal(signalName)
+	if err != nil {
 		return syscall.SIGTERM
 	}
+	return sig
 }
 
 // Kill 向指定的进程发送信号
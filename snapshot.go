@@ -0,0 +1,97 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// snapshotVersion 快照格式的版本号
+const snapshotVersion = 1
+
+// Snapshot 进程管理器的状态快照
+type Snapshot struct {
+	Version   int               `json:"version"`   // 快照格式版本
+	Time      time.Time         `json:"time"`      // 快照时间
+	Processes []ProcessSnapshot `json:"processes"` // 已注册的进程
+}
+
+// ProcessSnapshot 单个进程的快照
+type ProcessSnapshot struct {
+	Options Options `json:"options"` // 进程配置
+	Running bool    `json:"running"` // 期望的运行状态，true表示应该运行
+}
+
+// Snapshot 把已注册的进程、它们的配置以及期望的运行状态序列化为JSON
+func (m *Manager) Snapshot() ([]byte, error) {
+	snapshot := Snapshot{
+		Version: snapshotVersion,
+		Time:    time.Now(),
+	}
+	m.ForEachProcess(func(p *Process) {
+		p.lock.RLock()
+		snapshot.Processes = append(snapshot.Processes, ProcessSnapshot{
+			Options: p.option,
+			Running: p.inStart && !p.stopByUser,
+		})
+		p.lock.RUnlock()
+	})
+	sort.Slice(snapshot.Processes, func(i, j int) bool {
+		a, b := snapshot.Processes[i].Options, snapshot.Processes[j].Options
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.Name < b.Name
+	})
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+// Restore 从快照中恢复进程，并按照优先级把进程恢复到快照时的运行状态
+// 未注册的进程会使用快照中的配置注册，已注册但未运行的进程会使用快照中的配置替换原有配置
+func (m *Manager) Restore(data []byte) error {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("解析快照失败: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("不支持的快照版本: %d", snapshot.Version)
+	}
+	sort.SliceStable(snapshot.Processes, func(i, j int) bool {
+		return snapshot.Processes[i].Options.Priority < snapshot.Processes[j].Options.Priority
+	})
+
+	var errs []error
+	for _, item := range snapshot.Processes {
+		opts := item.Options
+		opts.ensureMaps()
+		if opts.Name == "" {
+			errs = append(errs, errors.New("快照中的进程缺少名称"))
+			continue
+		}
+
+		proc := m.Find(opts.Name)
+		if proc == nil {
+			var err error
+			if proc, err = m.NewProcessByOptions(opts); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		} else if !proc.isInStart() {
+			proc.lock.Lock()
+			proc.option = opts
+			proc.lock.Unlock()
+		}
+
+		switch {
+		case item.Running && !proc.isInStart():
+			m.logger.Infof("根据快照启动进程[%s]", opts.Name)
+			proc.Start(false)
+		case !item.Running && proc.isInStart():
+			m.logger.Infof("根据快照停止进程[%s]", opts.Name)
+			proc.Stop(true)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Stats 进程的资源使用情况
+type Stats struct {
+	Pid        int       `json:"pid"`         // 进程pid
+	CPUPercent float64   `json:"cpu_percent"` // CPU使用率，100表示占满一个核
+	RSS        uint64    `json:"rss"`         // 常驻内存(字节)
+	VMS        uint64    `json:"vms"`         // 虚拟内存(字节)
+	ReadBytes  uint64    `json:"read_bytes"`  // 累计从存储读取的字节数
+	WriteBytes uint64    `json:"write_bytes"` // 累计写入存储的字节数
+	OpenFDs    int       `json:"open_fds"`    // 打开的文件句柄数
+	Threads    int       `json:"threads"`     // 线程数
+	Processes  int       `json:"processes"`   // 参与统计的进程数，包含子进程时大于1
+	SampleTime time.Time `json:"sample_time"` // 采样时间
+}
+
+// statsSample 采样时的原始数据，用于计算两次采样之间的CPU使用率
+type statsSample struct {
+	stats    Stats
+	cpuTicks uint64
+}
+
+// Stats 获取进程的资源使用情况
+// 开启了定时采样时返回最近一次的采样结果，否则立即采样
+func (that *Process) Stats() (*Stats, error) {
+	that.statsLock.Lock()
+	last := that.lastStats
+	sampling := that.statsCancel != nil
+	that.statsLock.Unlock()
+	if sampling && last != nil {
+		stats := last.stats
+		return &stats, nil
+	}
+
+	pid := that.runningPid()
+	if pid <= 0 {
+		return nil, fmt.Errorf("进程[%s]没有运行", that.GetName())
+	}
+	return that.sampleStats(pid)
+}
+
+// 获取最近一次的采样结果，没有采样数据时返回nil
+func (that *Process) cachedStats() *Stats {
+	that.statsLock.Lock()
+	defer that.statsLock.Unlock()
+	if that.lastStats == nil || that.statsCancel == nil {
+		return nil
+	}
+	stats := that.lastStats.stats
+	return &stats
+}
+
+// 采样一次进程的资源使用情况，并计算与上一次采样之间的CPU使用率
+func (that *Process) sampleStats(pid int) (*Stats, error) {
+	sample, err := collectStats(pid, that.option.StatsWithChildren)
+	if err != nil {
+		return nil, err
+	}
+
+	that.statsLock.Lock()
+	defer that.statsLock.Unlock()
+	if last := that.lastStats; last != nil && last.stats.Pid == pid && sample.cpuTicks >= last.cpuTicks {
+		elapsed := sample.stats.SampleTime.Sub(last.stats.SampleTime).Seconds()
+		if elapsed > 0 {
+			sample.stats.CPUPercent = float64(sample.cpuTicks-last.cpuTicks) / clockTicks / elapsed * 100
+		}
+	}
+	that.lastStats = sample
+	stats := sample.stats
+	return &stats, nil
+}
+
+// 启动资源定时采样，未设置采样间隔时不启动
+func (that *Process) startStatsSampler(pid int) {
+	interval := that.statsInterval()
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	that.statsLock.Lock()
+	if that.statsCancel != nil {
+		that.statsCancel()
+	}
+	that.statsCancel = cancel
+	that.lastStats = nil
+	that.resetLimits()
+	that.statsLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		_, _ = that.sampleStats(pid)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stats, err := that.sampleStats(pid)
+				if err != nil {
+					that.Manager.logger.Debugf("采集进程[%s]资源使用情况失败: %v", that.GetName(), err)
+					continue
+				}
+				that.checkLimits(stats)
+			}
+		}
+	}()
+}
+
+// 停止资源定时采样
+func (that *Process) stopStatsSampler() {
+	that.statsLock.Lock()
+	defer that.statsLock.Unlock()
+	if that.statsCancel != nil {
+		that.statsCancel()
+		that.statsCancel = nil
+	}
+}
+
+// 获取正在运行的进程pid，进程未启动时返回0
+// cmd.ProcessState 由 cmd.Wait 在没有加锁的情况下写入，所以使用加锁记录的pid
+func (that *Process) runningPid() int {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	return that.runPid
+}
@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// collectStats 从 /proc 中读取进程(以及可选的所有后代进程)的资源使用情况
+func collectStats(pid int, withChildren bool) (*statsSample, error) {
+	pids := []int{pid}
+	if withChildren {
+		pids = append(pids, descendantPids(pid)...)
+	}
+
+	sample := &statsSample{stats: Stats{Pid: pid, SampleTime: time.Now()}}
+	pageSize := uint64(os.Getpagesize())
+	for i, p := range pids {
+		st, err := readProcStat(p)
+		if err != nil {
+			// 主进程读取失败说明进程已经退出，子进程可能在统计期间退出，直接忽略
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		sample.stats.Processes++
+		sample.cpuTicks += st.UTime + st.STime
+		sample.stats.Threads += st.Threads
+		if status, err := readProcStatus(p); err == nil {
+			sample.stats.RSS += parseKB(status["VmRSS"])
+			sample.stats.VMS += parseKB(status["VmSize"])
+		} else {
+			sample.stats.RSS += uint64(st.RSS) * pageSize
+			sample.stats.VMS += st.VSize
+		}
+		if io, err := readProcIO(p); err == nil {
+			readBytes, _ := strconv.ParseUint(io["read_bytes"], 10, 64)
+			writeBytes, _ := strconv.ParseUint(io["write_bytes"], 10, 64)
+			sample.stats.ReadBytes += readBytes
+			sample.stats.WriteBytes += writeBytes
+		}
+		if fds, err := countProcFDs(p); err == nil {
+			sample.stats.OpenFDs += fds
+		}
+	}
+	return sample, nil
+}
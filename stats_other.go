@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+// clockTicks 与Linux保持一致，非Linux系统不会用到
+const clockTicks = 100
+
+// collectStats 非Linux系统暂不支持资源统计
+func collectStats(_ int, _ bool) (*statsSample, error) {
+	return nil, errors.New("当前系统不支持进程资源统计")
+}
@@ -0,0 +1,198 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/darkit/process/signals"
+)
+
+// defaultStopStepTimeout 停止步骤中HTTP请求和命令默认的超时时间
+const defaultStopStepTimeout = 10 * time.Second
+
+// StopStep 停止计划中的一个步骤，Signal、HTTP、Command 三选一
+type StopStep struct {
+	Signal     string        `json:"signal" yaml:"signal"`           // 发送给进程的信号，例如 TERM
+	HTTP       string        `json:"http" yaml:"http"`               // 请求的地址，用于通知进程摘除流量
+	HTTPMethod string        `json:"http_method" yaml:"http_method"` // HTTP请求方法，默认POST
+	Command    string        `json:"command" yaml:"command"`         // 通过shell执行的命令，输出写入进程的日志
+	Timeout    time.Duration `json:"timeout" yaml:"timeout"`         // HTTP请求或命令的超时时间，默认10秒
+	Wait       time.Duration `json:"wait" yaml:"wait"`               // 执行后等待进程退出的时间，进程在此期间退出时不再执行后续步骤
+}
+
+// StopPlan 进程的停止计划，按顺序执行所有步骤后进程仍未退出时强制结束进程
+type StopPlan struct {
+	Steps       []StopStep    `json:"steps" yaml:"steps"`               // 停止步骤
+	KillTimeout time.Duration `json:"kill_timeout" yaml:"kill_timeout"` // 强制结束进程后等待的时间，默认使用 KillWaitSecs
+}
+
+// WithStopPlan 设置进程的停止计划，设置后 StopSignal 和 StopWaitSecs 不再生效
+func WithStopPlan(plan StopPlan) WithOption {
+	return func(options *Options) {
+		options.StopPlan = &plan
+	}
+}
+
+// WithStopStep 在停止计划中追加一个步骤
+func WithStopStep(step StopStep) WithOption {
+	return func(options *Options) {
+		if options.StopPlan == nil {
+			options.StopPlan = &StopPlan{}
+		}
+		options.StopPlan.Steps = append(options.StopPlan.Steps, step)
+	}
+}
+
+// 获取进程的停止计划，未设置时根据 StopSignal、StopWaitSecs、KillWaitSecs 生成
+func (that *Process) stopPlan() StopPlan {
+	plan := StopPlan{}
+	if that.option.StopPlan != nil {
+		plan.Steps = append(plan.Steps, that.option.StopPlan.Steps...)
+		plan.KillTimeout = that.option.StopPlan.KillTimeout
+	} else {
+		wait := that.option.stopWaitDuration()
+		for _, sig := range that.option.StopSignal {
+			plan.Steps = append(plan.Steps, StopStep{Signal: sig, Wait: wait})
+		}
+	}
+	if plan.KillTimeout <= 0 {
+		plan.KillTimeout = that.option.killWaitDuration()
+	}
+	return plan
+}
+
+// 计算执行完整个停止计划需要的最长时间
+func (that StopPlan) duration() time.Duration {
+	total := that.KillTimeout
+	for _, step := range that.Steps {
+		if step.Signal == "" {
+			total += step.timeout()
+		}
+		total += step.Wait
+	}
+	return total
+}
+
+// 获取HTTP请求或命令的超时时间
+func (that StopStep) timeout() time.Duration {
+	if that.Timeout > 0 {
+		return that.Timeout
+	}
+	return defaultStopStepTimeout
+}
+
+// 描述停止步骤，用于日志
+func (that StopStep) String() string {
+	switch {
+	case that.Signal != "":
+		return "信号" + that.Signal
+	case that.HTTP != "":
+		return "请求" + that.HTTP
+	case that.Command != "":
+		return "命令" + that.Command
+	default:
+		return "等待"
+	}
+}
+
+// 执行一个停止步骤
+func (that *Process) runStopStep(step StopStep, stopAsGroup bool, tree map[int]uint64) error {
+	switch {
+	case step.Signal != "":
+		sig, err := signals.ParseSignal(step.Signal)
+		if err != nil {
+			return err
+		}
+		_ = that.Signal(sig, stopAsGroup)
+		if tree != nil {
+			that.signalTree(tree, sig)
+		}
+		return nil
+	case step.HTTP != "":
+		ctx, cancel := context.WithTimeout(context.Background(), step.timeout())
+		defer cancel()
+		method := strings.ToUpper(step.HTTPMethod)
+		if method == "" {
+			method = http.MethodPost
+		}
+		req, err := http.NewRequestWithContext(ctx, method, step.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("请求返回状态码%d", resp.StatusCode)
+		}
+		return nil
+	case step.Command != "":
+		stdout, stderr, closeLog := that.hookOutput(HookPreStop)
+		defer closeLog()
+		return that.runHook(Hook{Command: step.Command, Timeout: step.timeout()}, HookEvent{
+			Stage:   HookPreStop,
+			Process: that.option.Name,
+			Pid:     that.runningPid(),
+		}, stdout, stderr)
+	default:
+		return nil
+	}
+}
+
+// 按照停止计划停止进程，返回进程是否在强制结束前退出
+func (that *Process) executeStopPlan(plan StopPlan) bool {
+	// 是否同时停止进程组
+	stopAsGroup := that.option.StopAsGroup
+	// 是否强制杀死进程组
+	killAsGroup := that.option.KillAsGroup
+	// 是否停止整个进程树，需要在主进程退出前记录下所有的子进程
+	var tree map[int]uint64
+	if that.option.StopAsTree {
+		tree = that.collectTree(nil)
+	}
+
+	for _, step := range plan.Steps {
+		that.Manager.logger.Infof("执行进程[%s]的停止步骤: %s", that.GetName(), step)
+		if tree != nil {
+			tree = that.collectTree(tree)
+		}
+		if err := that.runStopStep(step, stopAsGroup, tree); err != nil {
+			that.Manager.logger.Warnf("进程[%s]的停止步骤[%s]执行失败: %v", that.GetName(), step, err)
+		}
+		if that.waitStopped(step.Wait) {
+			return true
+		}
+	}
+
+	// 执行了所有步骤后，进程还未停止，则需要强制结束该进程
+	that.Manager.logger.Infof("强制结束程序[%s]", that.GetName())
+	if tree != nil {
+		tree = that.collectTree(tree)
+	}
+	_ = that.Signal(syscall.SIGKILL, killAsGroup)
+	if tree != nil {
+		that.signalTree(tree, syscall.SIGKILL)
+	}
+	that.waitStopped(plan.KillTimeout)
+	return false
+}
+
+// 等待进程退出，超时返回false
+func (that *Process) waitStopped(timeout time.Duration) bool {
+	endTime := time.Now().Add(timeout)
+	for {
+		if that.checkState() {
+			return true
+		}
+		if !time.Now().Before(endTime) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+package process
+
+// 加锁防止子进程回收协程在进程启动期间回收子进程
+// 进程可能在启动后立即退出，在它的pid被记录之前不能被当作孤儿进程回收
+func (m *Manager) lockSpawn() func() {
+	m.spawnLock.RLock()
+	return m.spawnLock.RUnlock
+}
+
+// 记录由管理器启动的子进程，它们的退出状态由对应的 Process 获取
+func (m *Manager) trackChild(pid int) {
+	m.childLock.Lock()
+	defer m.childLock.Unlock()
+	if m.children == nil {
+		m.children = make(map[int]struct{})
+	}
+	m.children[pid] = struct{}{}
+}
+
+// 移除子进程记录
+func (m *Manager) untrackChild(pid int) {
+	m.childLock.Lock()
+	defer m.childLock.Unlock()
+	delete(m.children, pid)
+}
+
+// 判断是否为管理器启动的子进程
+func (m *Manager) isTrackedChild(pid int) bool {
+	m.childLock.Lock()
+	defer m.childLock.Unlock()
+	_, ok := m.children[pid]
+	return ok
+}
@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// orphanReapDelay 僵尸进程需要存在超过该时间才会被回收，
+// 给宿主程序中其他代码通过 exec.Cmd.Wait 回收自己子进程的机会
+const orphanReapDelay = time.Second
+
+// EnableSubreaper 开启子进程收割模式
+// 通过 PR_SET_CHILD_SUBREAPER 把当前进程设置为子进程收割者，后代进程的父进程退出后会被重新挂到当前进程下，
+// 与 ReapZombie 不同，它不要求当前进程的pid为1，并且只回收不是由管理器启动的孤儿进程，
+// 管理器启动的进程的退出状态仍然由对应的 Process 获取，接管和回收孤儿进程时会产生相应的事件
+func (m *Manager) EnableSubreaper() error {
+	m.childLock.Lock()
+	if m.subreaper {
+		m.childLock.Unlock()
+		return nil
+	}
+	m.subreaper = true
+	m.childLock.Unlock()
+
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		m.childLock.Lock()
+		m.subreaper = false
+		m.childLock.Unlock()
+		return fmt.Errorf("设置子进程收割者失败: %w", err)
+	}
+	go m.reapOrphans()
+	return nil
+}
+
+// 收到 SIGCHLD 信号或定时扫描当前进程的子进程，回收不属于管理器的僵尸进程
+func (m *Manager) reapOrphans() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	ticker := time.NewTicker(orphanReapDelay)
+	defer ticker.Stop()
+
+	known := make(map[int]uint64)      // 已经上报过的孤儿进程pid与启动时间
+	zombies := make(map[int]time.Time) // 僵尸进程pid与第一次发现的时间
+	for {
+		m.scanOrphans(known, zombies)
+		select {
+		case <-sigs:
+		case <-ticker.C:
+		}
+	}
+}
+
+// 扫描一次当前进程的子进程
+func (m *Manager) scanOrphans(known map[int]uint64, zombies map[int]time.Time) {
+	// 扫描期间不允许启动新进程，避免刚启动就退出的进程在记录pid之前被回收
+	m.spawnLock.Lock()
+	defer m.spawnLock.Unlock()
+
+	stats, err := listProcStats()
+	if err != nil {
+		return
+	}
+	self := os.Getpid()
+	alive := make(map[int]struct{})
+	for _, st := range stats {
+		if st.PPid != self || m.isTrackedChild(st.Pid) {
+			continue
+		}
+		alive[st.Pid] = struct{}{}
+		if startTime, ok := known[st.Pid]; !ok || startTime != st.StartTime {
+			known[st.Pid] = st.StartTime
+			m.logger.Infof("接管孤儿进程[%d %s]", st.Pid, st.Comm)
+			m.emit(Event{Type: EventOrphanAdopted, Pid: st.Pid, Message: fmt.Sprintf("接管孤儿进程: %s", st.Comm)})
+		}
+		if st.State != 'Z' {
+			continue
+		}
+		firstSeen, ok := zombies[st.Pid]
+		if !ok {
+			zombies[st.Pid] = time.Now()
+			continue
+		}
+		if time.Since(firstSeen) < orphanReapDelay {
+			continue
+		}
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(st.Pid, &status, syscall.WNOHANG, nil)
+		if err != nil || pid != st.Pid {
+			continue
+		}
+		delete(known, st.Pid)
+		delete(zombies, st.Pid)
+		m.logger.Infof("回收孤儿进程[%d %s], %s", st.Pid, st.Comm, describeWaitStatus(status))
+		m.emit(Event{Type: EventOrphanReaped, Pid: st.Pid, Message: fmt.Sprintf("回收孤儿进程: %s, %s", st.Comm, describeWaitStatus(status))})
+	}
+	// 清理已经不存在(例如被其他代码回收)的进程记录
+	for pid := range known {
+		if _, ok := alive[pid]; !ok {
+			delete(known, pid)
+			delete(zombies, pid)
+		}
+	}
+}
+
+// 描述进程的退出状态
+func describeWaitStatus(status syscall.WaitStatus) string {
+	if status.Signaled() {
+		return fmt.Sprintf("被信号[%s]结束", status.Signal())
+	}
+	return fmt.Sprintf("退出码为:%d", status.ExitStatus())
+}
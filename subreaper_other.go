@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+// EnableSubreaper 开启子进程收割模式，仅Linux支持
+func (m *Manager) EnableSubreaper() error {
+	return errors.New("当前系统不支持子进程收割模式")
+}
@@ -0,0 +1,46 @@
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// Descendants 获取进程的所有后代进程pid，通过 /proc/*/stat 中的 PPid 关系查找，目前仅支持Linux
+func (that *Process) Descendants() []int {
+	pid := that.runningPid()
+	if pid <= 0 {
+		return nil
+	}
+	return descendantPids(pid)
+}
+
+// 记录进程树中所有进程的启动时间，发送信号前用于判断pid是否已经被复用
+// 进程树的主进程退出后，子进程会被重新挂到其他父进程下，所以需要在停止前先记录下来
+func (that *Process) collectTree(tree map[int]uint64) map[int]uint64 {
+	if tree == nil {
+		tree = make(map[int]uint64)
+	}
+	for _, pid := range that.Descendants() {
+		if _, ok := tree[pid]; ok {
+			continue
+		}
+		if st, err := readProcStat(pid); err == nil {
+			tree[pid] = st.StartTime
+		}
+	}
+	return tree
+}
+
+// 向进程树中仍然存活的进程发送信号
+func (that *Process) signalTree(tree map[int]uint64, sig os.Signal) {
+	for pid, startTime := range tree {
+		err := signalPidChecked(pid, startTime, sig)
+		if err == syscall.ESRCH {
+			delete(tree, pid)
+			continue
+		}
+		if err != nil {
+			that.Manager.logger.Infof("向进程[%s]的子进程[%d]发送信号[%s]失败: %v", that.GetName(), pid, sig, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// 设置当前线程的 umask，mask 为八进制字符串，为空时不修改
+// umask 保存在线程共享的文件系统信息中，先通过 unshare(CLONE_FS) 让当前线程拥有独立的副本，
+// 修改后不会影响其他线程创建文件，调用方需要锁定当前线程并且不再解锁
+func setThreadUmask(mask string) error {
+	if mask == "" {
+		return nil
+	}
+	val, err := parseUmask(mask)
+	if err != nil {
+		return err
+	}
+	if err = syscall.Unshare(syscall.CLONE_FS); err != nil {
+		return fmt.Errorf("设置umask失败: %w", err)
+	}
+	syscall.Umask(val)
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package process
+
+import (
+	"sync"
+	"syscall"
+)
+
+// umask 是进程级别的属性，修改期间需要加锁，避免多个进程同时启动时互相影响
+var umaskLock sync.Mutex
+
+// 在指定的 umask 下执行函数，mask 为八进制字符串，为空时不修改
+// 非Linux系统无法只修改单个线程的 umask，执行期间其他协程创建的文件同样使用该 umask
+func withUmask(mask string, fn func() error) error {
+	if mask == "" {
+		return fn()
+	}
+	val, err := parseUmask(mask)
+	if err != nil {
+		return err
+	}
+	umaskLock.Lock()
+	defer umaskLock.Unlock()
+	old := syscall.Umask(val)
+	defer syscall.Umask(old)
+	return fn()
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// 解析八进制的 umask，例如 "022"
+func parseUmask(mask string) (int, error) {
+	val, err := strconv.ParseUint(mask, 8, 32)
+	if err != nil || val > 0o777 {
+		return 0, fmt.Errorf("无效的umask[%s]", mask)
+	}
+	return int(val), nil
+}
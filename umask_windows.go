@@ -0,0 +1,8 @@
+//go:build windows
+
+package process
+
+// 在指定的 umask 下执行函数，windows 不支持 umask
+func withUmask(_ string, fn func() error) error {
+	return fn()
+}
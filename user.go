@@ -0,0 +1,50 @@
+package process
+
+import (
+	"strings"
+)
+
+// runUser 进程切换后的运行用户
+type runUser struct {
+	Uid  int    // 用户id
+	Gid  int    // 主用户组id
+	Name string // 用户名
+	Home string // 用户主目录
+}
+
+// 切换用户后需要调整的环境变量，已经在 Environment 中明确设置的变量不会被覆盖
+func (that *Process) userEnv() []string {
+	if that.runUser == nil {
+		return nil
+	}
+	values := map[string]string{
+		"HOME":    that.runUser.Home,
+		"USER":    that.runUser.Name,
+		"LOGNAME": that.runUser.Name,
+	}
+	explicit := that.option.Environment.Map()
+	var env []string
+	for _, key := range []string{"HOME", "USER", "LOGNAME"} {
+		if _, ok := explicit[key]; ok || values[key] == "" {
+			continue
+		}
+		env = append(env, key+"="+values[key])
+	}
+	return env
+}
+
+// 拆分以逗号分隔的多个日志文件
+func splitLogFiles(logFile string) []string {
+	var files []string
+	for _, f := range strings.Split(logFile, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// 是否是普通的日志文件，排除 syslog 和 /dev 下的特殊文件
+func isRegularLogFile(file string) bool {
+	return !strings.HasPrefix(file, "syslog") && !strings.HasPrefix(file, "/dev/")
+}
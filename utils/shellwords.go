@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// assignment 命令前的环境变量赋值，例如 FOO=bar cmd 中的 FOO=
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// noExecWords 不能加上 exec 前缀的shell关键字和内建命令
+var noExecWords = map[string]bool{
+	"exec": true, "if": true, "for": true, "while": true, "until": true, "case": true, "select": true,
+	"function": true, "time": true, "coproc": true, "!": true, "{": true, "[[": true,
+	"cd": true, "export": true, "source": true, ".": true, "eval": true, "set": true, "unset": true,
+	"readonly": true, "local": true, "trap": true, "ulimit": true, "umask": true, "alias": true, ":": true,
+}
+
+// CommandLine 按照shell的规则解析的命令行
+type CommandLine struct {
+	Env   map[string]string // 命令前的环境变量赋值
+	Args  []string          // 命令和参数，已经去掉引号和转义
+	Shell bool              // 是否包含变量、通配符、重定向、管道等需要shell处理的语法，为true时需要通过shell执行
+}
+
+// 命令行中的一个参数
+type shellWord struct {
+	value  string // 去掉引号和转义后的值
+	offset int    // 在命令行中的起始位置
+	end    int    // 在命令行中的结束位置
+	assign bool   // 是否为环境变量赋值
+}
+
+// 命令行的扫描结果
+type shellScan struct {
+	words []shellWord
+	shell bool // 包含需要shell处理的语法
+	list  bool // 包含管道、命令列表、后台执行或子shell，不是单个命令
+}
+
+// SplitCommand 按照shell的规则把命令行分割为参数列表，支持单引号、双引号、反斜杠转义和续行，
+// 变量、通配符等shell语法原样保留
+func SplitCommand(command string) ([]string, error) {
+	scan, err := scanCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, len(scan.words))
+	for _, word := range scan.words {
+		args = append(args, word.value)
+	}
+	return args, nil
+}
+
+// JoinCommand 把参数列表转换为 SplitCommand 可以还原的命令行，包含特殊字符的参数使用单引号包裹
+func JoinCommand(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%_+=:,./-") == "" {
+			quoted = append(quoted, arg)
+			continue
+		}
+		arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		// 空白字符后的 ; 在INI中表示注释，把 ; 放到单独的引号中
+		arg = strings.NewReplacer(" ;", " '';", "\t;", "\t'';").Replace(arg)
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ReplaceCommandArgs 按照shell的规则分割命令行，由 replace 修改参数列表(不能改变参数的数量)，
+// 修改了的参数重新加上引号写回原来的位置，命令行中的其他内容保持不变
+func ReplaceCommandArgs(command string, replace func(args []string)) (string, error) {
+	scan, err := scanCommand(command)
+	if err != nil {
+		return "", err
+	}
+	args := make([]string, len(scan.words))
+	for i, word := range scan.words {
+		args[i] = word.value
+	}
+	replace(args)
+	for i := len(scan.words) - 1; i >= 0; i-- {
+		word := scan.words[i]
+		if args[i] == word.value {
+			continue
+		}
+		quoted := JoinCommand(args[i : i+1])
+		if key, value, ok := strings.Cut(args[i], "="); ok && word.assign && assignment.MatchString(args[i]) {
+			// 环境变量赋值只给值加引号，整体加引号后不再是赋值
+			quoted = key + "=" + JoinCommand([]string{value})
+		}
+		command = command[:word.offset] + quoted + command[word.end:]
+	}
+	return command, nil
+}
+
+// ParseCommandLine 按照shell的规则解析命令行，命令前的环境变量赋值(例如 FOO=bar cmd)放在 Env 中
+func ParseCommandLine(command string) (CommandLine, error) {
+	scan, err := scanCommand(command)
+	if err != nil {
+		return CommandLine{}, err
+	}
+	line := CommandLine{Env: make(map[string]string), Shell: scan.shell}
+	for i, word := range scan.words {
+		if !word.assign {
+			for _, w := range scan.words[i:] {
+				line.Args = append(line.Args, w.value)
+			}
+			break
+		}
+		key, value, _ := strings.Cut(word.value, "=")
+		line.Env[key] = value
+	}
+	return line, nil
+}
+
+// ExecCommand 在通过shell执行的单个命令前加上 exec，shell会被该命令替换，信号直接发送给该命令；
+// 包含管道、命令列表或以shell关键字、内建命令开头的命令行原样返回
+func ExecCommand(command string) string {
+	scan, err := scanCommand(command)
+	if err != nil || scan.list {
+		return command
+	}
+	for _, word := range scan.words {
+		if word.assign {
+			continue
+		}
+		if noExecWords[word.value] {
+			return command
+		}
+		// 环境变量赋值需要在 exec 之前
+		return command[:word.offset] + "exec " + command[word.offset:]
+	}
+	return command
+}
+
+// 扫描命令行，分割参数并检查其中的shell语法
+func scanCommand(command string) (shellScan, error) {
+	var scan shellScan
+	var sb strings.Builder
+	inWord := false
+	started := false // 是否已经出现命令，只有命令前的赋值才是环境变量
+	start := 0
+	redirect := -1 // 最近一个不在引号中的 < 或 > 的位置
+	begin := func(i int) {
+		if !inWord {
+			inWord = true
+			start = i
+		}
+	}
+	end := func(i int) {
+		if inWord {
+			assign := !started && assignment.MatchString(command[start:])
+			started = !assign
+			scan.words = append(scan.words, shellWord{value: sb.String(), offset: start, end: i, assign: assign})
+			sb.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && i+1 < len(command):
+			i++
+			if command[i] == '\n' {
+				// 续行
+				continue
+			}
+			begin(i - 1)
+			sb.WriteByte(command[i])
+		case c == '\'':
+			begin(i)
+			n := strings.IndexByte(command[i+1:], '\'')
+			if n < 0 {
+				return scan, errors.New("单引号没有闭合")
+			}
+			sb.WriteString(command[i+1 : i+1+n])
+			i += n + 1
+		case c == '"':
+			begin(i)
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				switch {
+				case command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0:
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				case command[i] == '$' || command[i] == '`':
+					scan.shell = true
+				}
+				sb.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return scan, errors.New("双引号没有闭合")
+			}
+		case c == ' ' || c == '\t':
+			end(i)
+		case (c == '&' || c == '|') && redirect == i-1, c == '&' && strings.HasPrefix(command[i+1:], ">"):
+			// 重定向中的 &，例如 2>&1、>&2、<&0、&>file、&>>file，以及 >|file
+			scan.shell = true
+			begin(i)
+			sb.WriteByte(c)
+		case c == '\n' || strings.IndexByte("|&;()", c) >= 0:
+			end(i)
+			scan.shell, scan.list = true, true
+		default:
+			// {} 不会被展开，例如 find -exec rm {} \;
+			brace := c == '{' && !strings.HasPrefix(command[i:], "{}")
+			if brace || strings.IndexByte("<>$`*?[", c) >= 0 || (!inWord && strings.IndexByte("#~!", c) >= 0) {
+				scan.shell = true
+			}
+			if c == '<' || c == '>' {
+				redirect = i
+			}
+			begin(i)
+			sb.WriteByte(c)
+		}
+	}
+	end(len(command))
+	return scan, nil
+}
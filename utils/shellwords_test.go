@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		words   []string
+		shell   bool
+		list    bool
+	}{
+		{`./server --port 8080`, []string{"./server", "--port", "8080"}, false, false},
+		{`echo 'a b' "c d" e\ f`, []string{"echo", "a b", "c d", "e f"}, false, false},
+		{"run \\\n --flag", []string{"run", "--flag"}, false, false},
+		{`FOO=bar ./server`, []string{"FOO=bar", "./server"}, false, false},
+		{`find . -exec rm {} \;`, []string{"find", ".", "-exec", "rm", "{}", ";"}, false, false},
+		{`echo "$HOME"`, []string{"echo", "$HOME"}, true, false},
+		{`echo '$HOME'`, []string{"echo", "$HOME"}, false, false},
+		{`ls *.go`, []string{"ls", "*.go"}, true, false},
+		{`./server > out.log`, []string{"./server", ">", "out.log"}, true, false},
+		{`./server 2>&1`, []string{"./server", "2>&1"}, true, false},
+		{`./server >&2`, []string{"./server", ">&2"}, true, false},
+		{`./server <&0`, []string{"./server", "<&0"}, true, false},
+		{`./server &>out.log`, []string{"./server", "&>out.log"}, true, false},
+		{`./server &>> out.log`, []string{"./server", "&>>", "out.log"}, true, false},
+		{`./server >| out.log`, []string{"./server", ">|", "out.log"}, true, false},
+		{`./server > out.log 2>&1`, []string{"./server", ">", "out.log", "2>&1"}, true, false},
+		{`./server '2>&1'`, []string{"./server", "2>&1"}, false, false},
+		{`./server &`, []string{"./server"}, true, true},
+		{`./server && ./client`, []string{"./server", "./client"}, true, true},
+		{`./server \> &`, []string{"./server", ">"}, true, true},
+		{`./server | tee out.log`, []string{"./server", "tee", "out.log"}, true, true},
+		{`./server |& tee out.log`, []string{"./server", "tee", "out.log"}, true, true},
+		{`./migrate; ./server`, []string{"./migrate", "./server"}, true, true},
+		{`(./server)`, []string{"./server"}, true, true},
+	}
+	for _, tt := range tests {
+		scan, err := scanCommand(tt.command)
+		if err != nil {
+			t.Errorf("scanCommand(%q) error: %v", tt.command, err)
+			continue
+		}
+		var words []string
+		for _, word := range scan.words {
+			words = append(words, word.value)
+		}
+		if !reflect.DeepEqual(words, tt.words) || scan.shell != tt.shell || scan.list != tt.list {
+			t.Errorf("scanCommand(%q) = %q shell=%v list=%v, want %q shell=%v list=%v",
+				tt.command, words, scan.shell, scan.list, tt.words, tt.shell, tt.list)
+		}
+	}
+}
+
+func TestScanCommandError(t *testing.T) {
+	for _, command := range []string{`echo 'a`, `echo "a`} {
+		if _, err := scanCommand(command); err == nil {
+			t.Errorf("scanCommand(%q) expected error", command)
+		}
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`./server $PORT`, `exec ./server $PORT`},
+		{`FOO=$BAR ./server`, `FOO=$BAR exec ./server`},
+		{`./server > out.log 2>&1`, `exec ./server > out.log 2>&1`},
+		{`./server &>> out.log`, `exec ./server &>> out.log`},
+		{`./server >&2`, `exec ./server >&2`},
+		{`./server &`, `./server &`},
+		{`./server | tee out.log`, `./server | tee out.log`},
+		{`cd /srv && ./server`, `cd /srv && ./server`},
+		{`exec ./server`, `exec ./server`},
+		{`if true; then ./server; fi`, `if true; then ./server; fi`},
+		{`echo 'a`, `echo 'a`},
+	}
+	for _, tt := range tests {
+		if got := ExecCommand(tt.command); got != tt.want {
+			t.Errorf("ExecCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
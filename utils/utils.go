@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type StrStrMap struct {
@@ -57,6 +60,21 @@ func (m *StrStrMap) Map() map[string]string {
 	return data
 }
 
+func (m *StrStrMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Map())
+}
+
+func (m *StrStrMap) UnmarshalJSON(b []byte) error {
+	data := make(map[string]string)
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.data = data
+	m.mu.Unlock()
+	return nil
+}
+
 type AnyAnyMap struct {
 	mu   sync.RWMutex
 	data map[interface{}]interface{}
@@ -81,6 +99,40 @@ func (m *AnyAnyMap) Get(key interface{}) interface{} {
 	return val
 }
 
+func (m *AnyAnyMap) Map() map[interface{}]interface{} {
+	m.mu.RLock()
+	data := make(map[interface{}]interface{}, len(m.data))
+	for k, v := range m.data {
+		data[k] = v
+	}
+	m.mu.RUnlock()
+	return data
+}
+
+// MarshalJSON JSON的键只能是字符串，非字符串的键会被转换为字符串
+func (m *AnyAnyMap) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{})
+	for k, v := range m.Map() {
+		data[fmt.Sprint(k)] = v
+	}
+	return json.Marshal(data)
+}
+
+func (m *AnyAnyMap) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	data := make(map[interface{}]interface{}, len(raw))
+	for k, v := range raw {
+		data[k] = v
+	}
+	m.mu.Lock()
+	m.data = data
+	m.mu.Unlock()
+	return nil
+}
+
 func SearchBinary(binary string) string {
 	if filepath.IsAbs(binary) {
 		if Exists(binary) {
@@ -126,6 +178,13 @@ func GetBytes(size string, defaultSize int) int {
 	if size == "" {
 		return defaultSize
 	}
+	// 没有单位时表示字节数
+	if n, err := strconv.Atoi(size); err == nil {
+		return n
+	}
+	if len(size) < 3 {
+		return defaultSize
+	}
 
 	unit := size[len(size)-2:]
 	value := size[:len(size)-2]
@@ -186,3 +245,63 @@ func Map() map[string]string {
 func All() []string {
 	return os.Environ()
 }
+
+// sizeUnits 容量单位，与 supervisord 一致使用1024进制
+var sizeUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// FormatSize 格式化容量，能被单位整除时使用最大的单位，例如 52428800 格式化为 "50MB"，否则为字节数
+func FormatSize(size uint64) string {
+	for _, unit := range sizeUnits[:4] {
+		if size > 0 && size%unit.multiplier == 0 {
+			return strconv.FormatUint(size/unit.multiplier, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatUint(size, 10)
+}
+
+// ParseSize 解析容量，例如 "50MB"、"1GB"、"1024"，没有单位时表示字节数
+func ParseSize(value string) (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := uint64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的容量: %q", value)
+	}
+	return uint64(n * float64(multiplier)), nil
+}
+
+// ParseDuration 解析时长，没有单位时表示秒，例如 "10"、"500ms"、"2m"
+func ParseDuration(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("时长不能为负数: %q", value)
+		}
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无效的时长: %q", value)
+	}
+	return d, nil
+}
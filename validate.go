@@ -0,0 +1,185 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/darkit/process/signals"
+)
+
+// FieldError 单个配置项的错误
+type FieldError struct {
+	Field string // 配置项路径，与序列化和配置文件中的键一致，例如 stop_plan.steps[0].signal
+	Err   error  // 错误原因
+}
+
+// Error 实现 error 接口，格式为 "配置项: 错误原因"
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap 返回错误原因
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 进程配置的校验错误，包含所有无效的配置项
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error 实现 error 接口，每行一个配置项的错误
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap 返回所有配置项的错误，可以通过 errors.As 获取其中的 *FieldError
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// validator 收集配置项的错误
+type validator struct {
+	errs []*FieldError
+}
+
+// 记录配置项的错误
+func (v *validator) add(field string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, &FieldError{Field: field, Err: err})
+	}
+}
+
+// 条件不成立时记录配置项的错误
+func (v *validator) check(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.add(field, fmt.Errorf(format, args...))
+	}
+}
+
+// 检查时长等不能为负数的配置项
+func (v *validator) notNegative(field string, value int64) {
+	v.check(value >= 0, field, "不能为负数")
+}
+
+// Validate 检查进程配置，返回 *ValidationError，其中包含所有无效的配置项
+func (that *Options) Validate() error {
+	v := &validator{}
+	v.check(that.Name != "", "name", "不能为空")
+	v.check(strings.TrimSpace(that.Command) != "", "command", "不能为空")
+	v.check(that.AutoReStart <= AutoReStartFalse, "auto_restart", "无效的自动重启策略[%d]", that.AutoReStart)
+	v.notNegative("start_secs", int64(that.startDuration()))
+	v.notNegative("start_retries", int64(that.StartRetries))
+	v.notNegative("restart_pause", int64(that.restartPauseDuration()))
+	if that.Umask != "" {
+		val, err := strconv.ParseUint(that.Umask, 8, 32)
+		v.check(err == nil && val <= 0o777, "umask", "无效的umask[%s]，应为八进制字符串，例如022", that.Umask)
+	}
+	v.check(that.Nice >= -20 && that.Nice <= 19, "nice", "取值范围为-20到19")
+	switch that.IOClass {
+	case IOClassNone, IOClassRealtime, IOClassBestEffort, IOClassIdle:
+	default:
+		v.add("io_class", fmt.Errorf("不支持的IO调度类型[%s]，可选值：realtime,best-effort,idle", that.IOClass))
+	}
+	v.check(that.IOPriority >= 0 && that.IOPriority <= 7, "io_priority", "取值范围为0到7")
+	for i, cpu := range that.CPUAffinity {
+		v.check(cpu >= 0, fmt.Sprintf("cpu_affinity[%d]", i), "无效的CPU编号[%d]", cpu)
+	}
+	v.check(that.OOMScoreAdj >= -1000 && that.OOMScoreAdj <= 1000, "oom_score_adj", "取值范围为-1000到1000")
+
+	v.notNegative("stdout_logfile_max_bytes", int64(that.StdoutLogFileMaxBytes))
+	v.notNegative("stdout_logfile_backups", int64(that.StdoutLogFileBackups))
+	v.notNegative("stderr_logfile_max_bytes", int64(that.StderrLogFileMaxBytes))
+	v.notNegative("stderr_logfile_backups", int64(that.StderrLogFileBackups))
+
+	// 只向进程组发送停止信号而不强杀进程组时，强杀后进程组中的其他进程会成为孤儿进程
+	v.check(!that.StopAsGroup || that.KillAsGroup, "kill_as_group", "设置 stop_as_group 时必须同时设置 kill_as_group")
+	for i, name := range that.StopSignal {
+		_, err := signals.ParseSignal(name)
+		v.add(fmt.Sprintf("stop_signal[%d]", i), err)
+	}
+	if that.StopPlan != nil {
+		that.StopPlan.validate(v, "stop_plan")
+	}
+	v.notNegative("stop_wait_secs", int64(that.stopWaitDuration()))
+	v.notNegative("kill_wait_secs", int64(that.killWaitDuration()))
+	if that.Environment != nil {
+		for key := range that.Environment.Map() {
+			v.check(key != "" && !strings.ContainsAny(key, "=\x00"), "environment", "无效的环境变量名[%s]", key)
+		}
+	}
+	if that.Isolation != nil {
+		var joined interface{ Unwrap() []error }
+		if err := that.Isolation.validate(that.User); errors.As(err, &joined) {
+			for _, e := range joined.Unwrap() {
+				v.add("isolation", e)
+			}
+		}
+	}
+	that.Hooks.validate(v)
+
+	v.notNegative("stats_interval", int64(that.StatsInterval))
+	v.check(that.CPULimit >= 0, "cpu_limit", "不能为负数")
+	v.notNegative("memory_limit_duration", int64(that.MemoryLimitDuration))
+	v.notNegative("cpu_limit_duration", int64(that.CPULimitDuration))
+
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+// 检查停止计划
+func (that *StopPlan) validate(v *validator, field string) {
+	for i, step := range that.Steps {
+		path := fmt.Sprintf("%s.steps[%d]", field, i)
+		n := 0
+		for _, set := range []bool{step.Signal != "", step.HTTP != "", step.Command != ""} {
+			if set {
+				n++
+			}
+		}
+		v.check(n == 1, path, "signal、http、command 必须且只能设置一个")
+		if step.Signal != "" {
+			_, err := signals.ParseSignal(step.Signal)
+			v.add(path+".signal", err)
+		}
+		if step.HTTP != "" {
+			u, err := url.Parse(step.HTTP)
+			v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", path+".http", "无效的地址[%s]", step.HTTP)
+		}
+		if step.HTTPMethod != "" {
+			switch strings.ToUpper(step.HTTPMethod) {
+			case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				v.add(path+".http_method", fmt.Errorf("不支持的请求方法[%s]", step.HTTPMethod))
+			}
+		}
+		v.notNegative(path+".timeout", int64(step.Timeout))
+		v.notNegative(path+".wait", int64(step.Wait))
+	}
+	v.notNegative(field+".kill_timeout", int64(that.KillTimeout))
+}
+
+// 检查生命周期钩子
+func (that *Hooks) validate(v *validator) {
+	for _, stage := range []HookStage{HookPreStart, HookPostStart, HookPreStop, HookPostStop} {
+		for i, hook := range that.get(stage) {
+			path := fmt.Sprintf("hooks.%s[%d]", stage, i)
+			v.check(hook.Command != "" || hook.Func != nil, path, "必须设置 command 或 Func")
+			v.notNegative(path+".timeout", int64(hook.Timeout))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package process
+
+import (
+	"fmt"
+	"time"
+)
+
+// 设置了资源限制但没有设置采样间隔时使用的默认采样间隔
+const defaultLimitStatsInterval = 5 * time.Second
+
+// 资源定时采样的间隔，返回0表示不需要定时采样
+func (that *Process) statsInterval() time.Duration {
+	if that.option.StatsInterval > 0 {
+		return that.option.StatsInterval
+	}
+	if that.option.MemoryLimit > 0 || that.option.CPULimit > 0 {
+		return defaultLimitStatsInterval
+	}
+	return 0
+}
+
+// 检查资源使用是否持续超出限制，超出限制的进程会被平滑重启
+func (that *Process) checkLimits(stats *Stats) {
+	reason := that.exceededLimit(stats)
+	if reason == "" {
+		return
+	}
+
+	that.Manager.logger.Warnf("进程[%s]%s, 准备重启进程", that.GetName(), reason)
+	that.lock.Lock()
+	that.restartReason = reason
+	that.lock.Unlock()
+	that.Manager.emit(Event{
+		Type:    EventLimitExceeded,
+		Process: that.GetName(),
+		Pid:     stats.Pid,
+		Message: reason,
+	})
+	go that.restart()
+}
+
+// 判断资源使用是否已经持续超出限制，返回超出的原因，未超出时返回空字符串
+func (that *Process) exceededLimit(stats *Stats) string {
+	that.statsLock.Lock()
+	defer that.statsLock.Unlock()
+	if that.limitTriggered {
+		return ""
+	}
+
+	now := stats.SampleTime
+	memoryLimit := that.option.MemoryLimit
+	cpuLimit := that.option.CPULimit
+	reason := ""
+	if memoryLimit > 0 && stats.RSS > memoryLimit {
+		if that.memoryOverSince.IsZero() {
+			that.memoryOverSince = now
+		}
+		if now.Sub(that.memoryOverSince) >= that.option.MemoryLimitDuration {
+			reason = fmt.Sprintf("内存使用%d字节超出限制%d字节已持续%s", stats.RSS, memoryLimit, now.Sub(that.memoryOverSince).Truncate(time.Second))
+		}
+	} else {
+		that.memoryOverSince = time.Time{}
+	}
+	if cpuLimit > 0 && stats.CPUPercent > cpuLimit {
+		if that.cpuOverSince.IsZero() {
+			that.cpuOverSince = now
+		}
+		if reason == "" && now.Sub(that.cpuOverSince) >= that.option.CPULimitDuration {
+			reason = fmt.Sprintf("CPU使用率%.1f%%超出限制%.1f%%已持续%s", stats.CPUPercent, cpuLimit, now.Sub(that.cpuOverSince).Truncate(time.Second))
+		}
+	} else {
+		that.cpuOverSince = time.Time{}
+	}
+	if reason != "" {
+		that.limitTriggered = true
+	}
+	return reason
+}
+
+// 重置资源超限的检查状态
+func (that *Process) resetLimits() {
+	that.memoryOverSince = time.Time{}
+	that.cpuOverSince = time.Time{}
+	that.limitTriggered = false
+}
+
+// 通过 Stop 的信号流程停止进程，然后重新启动
+// 上一次的启动协程没有按时退出时不再启动，避免被当作重复启动而失败，并重置检查状态，之后超限时再次尝试
+func (that *Process) restart() {
+	that.Stop(true)
+	if !that.waitStartExit() {
+		that.Manager.logger.Errorf("进程[%s]没有按时停止, 放弃本次重启", that.GetName())
+		that.statsLock.Lock()
+		that.resetLimits()
+		that.statsLock.Unlock()
+		that.Manager.emit(Event{
+			Type:    EventRestartFailed,
+			Process: that.GetName(),
+			Message: "进程没有按时停止, 放弃本次重启",
+		})
+		return
+	}
+	that.Start(false)
+}
+
+// 等待上一次的启动协程退出，否则再次启动会被当作重复启动，最多等待5秒，返回启动协程是否已经退出
+func (that *Process) waitStartExit() bool {
+	for i := 0; i < 500 && that.isInStart(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return !that.isInStart()
+}
+
+// 是否正在启动中
+func (that *Process) isInStart() bool {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	return that.inStart
+}
+
+// GetRestartReason 获取进程最近一次被自动重启的原因
+func (that *Process) GetRestartReason() string {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	return that.restartReason
+}